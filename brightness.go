@@ -0,0 +1,81 @@
+package drivers
+
+import (
+	"math"
+	"time"
+)
+
+// MaxDuty is the full-scale value returned by a BrightnessCurve.
+const MaxDuty = 0xFFFF
+
+// BrightnessCurve maps a perceptual brightness level (0–255) onto a linear
+// duty cycle in the range 0..MaxDuty. Level 0 must map to 0.
+type BrightnessCurve func(level uint8) uint16
+
+// LinearBrightness maps levels onto duty proportionally. It is what the
+// drivers used before curves were introduced.
+func LinearBrightness(level uint8) uint16 {
+	return uint16(uint32(level) * MaxDuty / 255)
+}
+
+var cie1931Table *[256]uint16
+
+// CIE1931Brightness maps levels onto duty using the CIE 1931 lightness
+// formula, so equal steps in level look like equal steps in brightness.
+func CIE1931Brightness(level uint8) uint16 {
+	if cie1931Table == nil {
+		t := new([256]uint16)
+		for i := range t {
+			l := float64(i) * 100 / 255
+			var y float64
+			if l <= 8 {
+				y = l / 903.3
+			} else {
+				y = math.Pow((l+16)/116, 3)
+			}
+			t[i] = uint16(math.Round(y * MaxDuty))
+		}
+		cie1931Table = t
+	}
+	return cie1931Table[level]
+}
+
+// GammaBrightness returns a curve that maps levels onto duty as
+// (level/255)^gamma. A gamma of 2.2 is a common choice for LED backlights.
+func GammaBrightness(gamma float64) BrightnessCurve {
+	t := new([256]uint16)
+	for i := range t {
+		t[i] = uint16(math.Round(math.Pow(float64(i)/255, gamma) * MaxDuty))
+	}
+	return func(level uint8) uint16 {
+		return t[level]
+	}
+}
+
+// BrightnessDuty returns the duty for level using curve. Every non-zero level
+// yields at least minDuty, so the lowest steps stay visible; the curve is
+// rescaled to span minDuty..MaxDuty. A nil curve is treated as linear.
+func BrightnessDuty(curve BrightnessCurve, level uint8, minDuty uint16) uint16 {
+	if level == 0 {
+		return 0
+	}
+	if curve == nil {
+		curve = LinearBrightness
+	}
+	d := uint32(curve(level))
+	return uint16(uint32(minDuty) + d*(MaxDuty-uint32(minDuty))/MaxDuty)
+}
+
+// fadeStep is the interval between brightness updates during a fade.
+const fadeStep = 10 * time.Millisecond
+
+// FadeBrightness calls set with levels stepping from one to another over
+// duration d. It blocks until the fade is done and always finishes with set(to).
+func FadeBrightness(from, to uint8, d time.Duration, set func(level uint8)) {
+	steps := int(d / fadeStep)
+	for i := 1; i < steps; i++ {
+		set(uint8(int(from) + (int(to)-int(from))*i/steps))
+		time.Sleep(fadeStep)
+	}
+	set(to)
+}
@@ -5,6 +5,8 @@
 //
 //   - st7789: TFT display driver optimized for LilyGo devices
 //   - tdeck:  T-Deck keyboard (I2C), trackball (GPIO), backlight, key codes
+//   - gt911:  GT911 capacitive touch controller (I2C)
+//   - input:  one event queue for keyboard, trackball and touch
 //
 // Example usage:
 //
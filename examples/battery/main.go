@@ -6,9 +6,11 @@ import (
 	"strconv"
 	"time"
 
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
 	"github.com/dimajolkin/tinygo-lilygo-drivers/st7789"
 	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
 	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/sdcard"
 )
 
 const (
@@ -25,9 +27,13 @@ const (
 	screenW = 320
 	screenH = 240
 
-	fontScale  = 2
-	margin     = 10
-	batteryMAh = 1400
+	fontScale = 2
+	margin    = 10
+
+	// Калибровка батареи хранится в сырых секторах SD-карты до первого
+	// раздела, после области калибровки тачскрина из примера tdeck-touch.
+	storeOffset = 66 * 512
+	storeSize   = 2 * 512
 )
 
 var (
@@ -39,9 +45,39 @@ var (
 	lastPct      int   = -1
 	lastVbatMV   int32 = -1
 	lastCharging bool
-	lastTimeLeft string
+
+	spiDisplay = machine.SPIConfig{
+		Frequency: 80000000,
+		SCK:       TFT_SCLK,
+		SDO:       TFT_MOSI,
+		Mode:      0,
+	}
 )
 
+// sdStore перенастраивает общую с дисплеем шину SPI вокруг обращений к карте:
+// журнал калибровки пишется прямо из Battery.Read.
+type sdStore struct {
+	sd    *sdcard.Device
+	spi   *machine.SPI
+	store *lilygo.BlockStore
+}
+
+func (s *sdStore) Load(key string, buf []byte) (int, error) {
+	defer s.spi.Configure(spiDisplay)
+	if err := s.sd.Configure(); err != nil {
+		return 0, err
+	}
+	return s.store.Load(key, buf)
+}
+
+func (s *sdStore) Save(key string, data []byte) error {
+	defer s.spi.Configure(spiDisplay)
+	if err := s.sd.Configure(); err != nil {
+		return err
+	}
+	return s.store.Save(key, data)
+}
+
 func main() {
 	time.Sleep(1 * time.Second)
 
@@ -50,12 +86,16 @@ func main() {
 	time.Sleep(200 * time.Millisecond)
 
 	spi := machine.SPI1
-	spi.Configure(machine.SPIConfig{
-		Frequency: 80000000,
-		SCK:       TFT_SCLK,
-		SDO:       TFT_MOSI,
-		Mode:      0,
-	})
+	sd := sdcard.New(spi, machine.Pin(tdeck.SDCardSCK), machine.Pin(tdeck.SDCardMOSI), machine.Pin(tdeck.SDCardMISO), machine.Pin(tdeck.SDCardCS))
+	var store *sdStore
+	if err := sd.Configure(); err != nil {
+		println("SD:", err.Error(), "- калибровка не сохранится")
+	} else if bs, err := lilygo.NewBlockStore(&sd, storeOffset, storeSize); err != nil {
+		println("store:", err.Error())
+	} else {
+		store = &sdStore{sd: &sd, spi: spi, store: bs}
+	}
+	spi.Configure(spiDisplay)
 
 	display := st7789.New(spi, TFT_RST, TFT_DC, TFT_CS, TFT_BL)
 	display.Configure(st7789.Config{
@@ -75,27 +115,85 @@ func main() {
 		return
 	}
 
-	kb := tdeck.New(machine.I2C0, TFT_RST)
+	// Питание клавиатуры (GPIO10) уже включено выше: это же сброс дисплея,
+	// поэтому драйвер клавиатуры не должен его трогать.
+	kb := tdeck.New(machine.I2C0, machine.NoPin)
 	kb.PowerOn()
 	time.Sleep(100 * time.Millisecond)
 	_ = kb.SetBrightness(127)
 
-	bat := tdeck.NewBattery(tdeck.BatteryADCPin, tdeck.DefaultBatteryConfig())
-	bat.Configure()
+	cfg := tdeck.DefaultBatteryConfig()
+	cfg.CapacityMAh = 1400
+	if store != nil {
+		cfg.Store = store
+	}
+	bat := tdeck.NewBattery(tdeck.BatteryADCPin, cfg)
+	if err := bat.Configure(); err != nil {
+		println("battery calibration:", err.Error())
+	}
+	if bat.CalibrationPending() {
+		// Прошлый разряд закончился выключением платы — достраиваем кривую.
+		if c, err := bat.FinishCalibration(); err != nil {
+			println("finish calibration:", err.Error())
+		} else {
+			println("calibration: capacity_mah:", c.CapacityMAh)
+		}
+	}
+	bat.SetAlertHandler(func(e tdeck.BatteryAlertEvent) {
+		println("battery alert:", e.Alert.String(), "pct:", e.Pct, "voltage_mv:", e.VoltageMV)
+	})
+	bat.SetShutdownPolicy(&tdeck.ShutdownPolicy{
+		Dim: func() {
+			// Idle-режим: 8 цветов, ниже частота кадров и токи драйвера —
+			// для экрана заряда этого достаточно.
+			display.EnterIdleMode()
+			display.SetBacklightBrightness(20)
+			bat.SetBacklightLoad(20)
+		},
+		Sleep: func() {
+			// Без потребителей плата почти не тратит заряд и не уходит в
+			// brown-out; вернуть её к жизни можно только сбросом.
+			display.SetBacklightBrightness(0)
+			display.Sleep(true)
+			boardPower.Low()
+			println("battery critical: board powered down")
+			for {
+				time.Sleep(time.Hour)
+			}
+		},
+	})
+	println("Клавиши: c — начать калибровку разрядом (с полной батареей, без USB), f — закончить.")
+	bat.SetBacklightLoad(180)
+	bat.SetKeyboardLightLoad(127)
 
 	for {
+		switch key, _ := kb.ReadKey(); key {
+		case 'c':
+			bat.StartCalibration()
+			println("calibration: started")
+		case 'f':
+			if c, err := bat.FinishCalibration(); err != nil {
+				println("finish calibration:", err.Error())
+			} else {
+				println("calibration: capacity_mah:", c.CapacityMAh)
+			}
+		}
+
 		r := bat.Read()
-		println("voltage_mv:", r.VoltageMV, "raw_adc:", r.RawADC, "pct:", r.Pct, "charging:", r.Charging)
+		println("voltage_mv:", r.VoltageMV, "open_mv:", r.OpenMV, "load_ma:", r.LoadMA, "raw_adc:", r.RawADC, "pct:", r.Pct, "state:", r.State.String())
 
-		//changed := lastPct != r.Pct || lastVbatMV != r.VoltageMV || lastCharging != r.Charging || lastTimeLeft != r.TimeLeft
+		//changed := lastPct != r.Pct || lastVbatMV != r.VoltageMV || lastCharging != r.Charging
 		if true {
 			lastPct = r.Pct
 			lastVbatMV = r.VoltageMV
 			lastCharging = r.Charging
-			lastTimeLeft = r.TimeLeft
 
 			display.FillScreen(bgColor)
-			display.DrawString(margin, margin, "Battery "+strconv.Itoa(batteryMAh)+" mAh", textColor, fontScale)
+			capMAh := cfg.CapacityMAh
+			if c := bat.Calibration().CapacityMAh; c > 0 {
+				capMAh = c
+			}
+			display.DrawString(margin, margin, "Battery "+strconv.Itoa(int(capMAh))+" mAh", textColor, fontScale)
 			if r.Charging {
 				display.DrawString(margin, margin+30, "Charge: "+strconv.Itoa(r.Pct)+"%", textColor, fontScale)
 			} else {
@@ -111,13 +209,23 @@ func main() {
 			display.FillRectangle(barX, barY, barW, barH, barBgColor)
 			display.FillRectangle(barX+2, barY+2, int16(r.Pct)*(barW-4)/100, barH-4, barFgColor)
 
-			if r.Charging {
+			switch r.State {
+			case tdeck.ChargeCharging:
 				display.DrawString(margin, margin+160, "CHARGING", textColor, fontScale)
-				if r.Pct >= 100 {
-					display.DrawString(margin, margin+188, "Full", textColor, fontScale)
-				} else if r.TimeLeft != "" {
-					display.DrawString(margin, margin+188, "~"+r.TimeLeft+" left", textColor, fontScale)
+				if r.TimeToFull > 0 {
+					display.DrawString(margin, margin+188, "~"+tdeck.FormatBatteryTime(r.TimeToFull)+" to full", textColor, fontScale)
 				}
+			case tdeck.ChargeFull:
+				display.DrawString(margin, margin+160, "Full", textColor, fontScale)
+			case tdeck.ChargeNoBattery:
+				display.DrawString(margin, margin+160, "No battery", textColor, fontScale)
+			case tdeck.ChargeDischarging:
+				if r.TimeToEmpty > 0 {
+					display.DrawString(margin, margin+160, "~"+tdeck.FormatBatteryTime(r.TimeToEmpty)+" left", textColor, fontScale)
+				}
+			}
+			if bat.CalibrationRunning() {
+				display.DrawString(margin, margin+216, "Calibrating...", textColor, fontScale)
 			}
 		}
 
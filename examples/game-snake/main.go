@@ -5,6 +5,8 @@ import (
 	"machine"
 	"time"
 
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
+	"github.com/dimajolkin/tinygo-lilygo-drivers/input"
 	"github.com/dimajolkin/tinygo-lilygo-drivers/st7789"
 	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
 	"tinygo.org/x/drivers"
@@ -43,6 +45,10 @@ const (
 	speakerSampleRate = 16000
 	beepHz            = 1000
 	beepDur           = 80 * time.Millisecond
+
+	minBacklightDuty = lilygo.MaxDuty / 100
+	brightnessStep   = 25
+	fadeDur          = 200 * time.Millisecond
 )
 
 var (
@@ -91,6 +97,7 @@ type game struct {
 	over            bool
 	display         *st7789.Device
 	battery         *tdeck.Battery
+	nav             *tdeck.Navigator
 	needFullDraw    bool
 	dirty           [8]vec2
 	ndirty          int
@@ -122,6 +129,7 @@ func main() {
 	blPWM := machine.PWM0
 	blPWM.Configure(machine.PWMConfig{Period: uint64(time.Second / 5000)})
 	display.ConfigureBacklightPWM(blPWM)
+	display.SetBacklightCurve(lilygo.CIE1931Brightness, minBacklightDuty)
 
 	display.SetBacklightBrightness(100)
 
@@ -131,7 +139,9 @@ func main() {
 		return
 	}
 
-	kb := tdeck.New(machine.I2C0, TFT_RST)
+	// Питание клавиатуры (GPIO10) уже включено выше: это же сброс дисплея,
+	// поэтому драйвер клавиатуры не должен его трогать.
+	kb := tdeck.New(machine.I2C0, machine.NoPin)
 	kb.PowerOn()
 	time.Sleep(100 * time.Millisecond)
 	_ = kb.SetBrightness(127)
@@ -140,12 +150,14 @@ func main() {
 
 	bat := tdeck.NewBattery(tdeck.BatteryADCPin, tdeck.DefaultBatteryConfig())
 	bat.Configure()
+	bat.SetKeyboardLightLoad(127)
 
 	initSpeaker()
 
 	g := &game{
 		display:        &display,
 		battery:        bat,
+		nav:            tdeck.NewNavigator(),
 		brightness:     128,
 		lastBrightness: -1,
 		lastBatPct:     -1,
@@ -153,53 +165,27 @@ func main() {
 		lastSoundOn:    true,
 	}
 	g.reset()
+	g.changeBrightness(0)
+
+	hk := tdeck.NewHotkeys()
+	hk.Bind(tdeck.Chord{Code: tdeck.KeySpeaker}, g.toggleSound)
+	onKey := hk.Handler(g.handleKey)
+	in := input.New(input.Keyboard(kb), input.Trackball(tb))
 
 	for {
-		code, _ := kb.ReadKey()
-		if code != 0 {
-			if code == 4 {
-				g.soundOn = !g.soundOn
-				g.needFullDraw = true
-			} else if g.over && (code == '+' || code == '-') {
-				step := uint8(25)
-				if code == '+' && g.brightness < 255 {
-					if 255-g.brightness < step {
-						g.brightness = 255
-					} else {
-						g.brightness += step
-					}
-				} else if code == '-' && g.brightness > 0 {
-					if g.brightness < step {
-						g.brightness = 0
-					} else {
-						g.brightness -= step
-					}
-				}
-				display.SetBacklightBrightness(g.brightness)
-			} else if g.over && (code == ' ' || code == 'r' || code == 'R') {
-				g.reset()
-			} else if !g.over && code == ' ' {
-				g.paused = !g.paused
-				if g.paused {
-					display.SetBacklightBrightness(40)
-				} else {
-					display.SetBacklightBrightness(g.brightness)
-					g.needFullDraw = true
+		for ev, ok := in.Next(0); ok; ev, ok = in.Next(0) {
+			switch ev.Kind {
+			case input.KindKey:
+				onKey(ev.Key)
+			case input.KindMotion:
+				g.inputTrackball(ev.DX, ev.DY)
+			case input.KindButton:
+				if ev.Button == tdeck.ButtonClick && g.over {
+					g.reset()
 				}
-			} else if !g.over {
-				g.input(byte(code))
-			}
-		}
-
-		dx, dy := tb.ReadMotion()
-		if dx != 0 || dy != 0 {
-			g.inputTrackball(dx, dy)
-		}
-		if g.over {
-			if s := tb.Read(); s.OK {
-				g.reset()
 			}
 		}
+		hk.Tick(time.Now())
 
 		if !g.over && !g.paused {
 			g.tick()
@@ -298,45 +284,93 @@ func (g *game) reset() {
 	g.needFullDraw = true
 }
 
+func (g *game) toggleSound() {
+	g.soundOn = !g.soundOn
+	g.needFullDraw = true
+}
+
+func (g *game) handleKey(ev tdeck.KeyEvent) {
+	if !ev.Pressed {
+		return
+	}
+	if g.over {
+		switch ev.Code {
+		case '+':
+			g.changeBrightness(brightnessStep)
+		case '-':
+			g.changeBrightness(-brightnessStep)
+		case tdeck.KeySpace, 'r', 'R':
+			g.reset()
+		}
+		return
+	}
+	if ev.Repeat {
+		return
+	}
+	if ev.Code == tdeck.KeySpace {
+		g.paused = !g.paused
+		if g.paused {
+			g.display.FadeBacklight(40, fadeDur)
+		} else {
+			g.display.FadeBacklight(g.brightness, fadeDur)
+			g.needFullDraw = true
+		}
+		return
+	}
+	g.input(ev.Code)
+}
+
+func (g *game) changeBrightness(delta int) {
+	b := int(g.brightness) + delta
+	if b < 0 {
+		b = 0
+	}
+	if b > 255 {
+		b = 255
+	}
+	g.brightness = uint8(b)
+	g.display.SetBacklightBrightness(g.brightness)
+	// Батарея учитывает ток подсветки, чтобы процент не прыгал от яркости.
+	g.battery.SetBacklightLoad(uint8(lilygo.BrightnessDuty(lilygo.CIE1931Brightness, g.brightness, minBacklightDuty) >> 8))
+}
+
 func (g *game) input(key byte) {
 	switch key {
-	case 'w', 'W':
+	case 'w', 'W', tdeck.KeyUp:
 		if g.dir.y == 0 {
 			g.next = vec2{0, -1}
 		}
-	case 's', 'S':
+	case 's', 'S', tdeck.KeyDown:
 		if g.dir.y == 0 {
 			g.next = vec2{0, 1}
 		}
-	case 'a', 'A':
+	case 'a', 'A', tdeck.KeyLeft:
 		if g.dir.x == 0 {
 			g.next = vec2{-1, 0}
 		}
-	case 'd', 'D':
+	case 'd', 'D', tdeck.KeyRight:
 		if g.dir.x == 0 {
 			g.next = vec2{1, 0}
 		}
 	}
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 func (g *game) inputTrackball(dx, dy int) {
-	if abs(dx) >= abs(dy) {
-		if dx > 0 && g.dir.x == 0 {
+	switch g.nav.Update(dx, dy) {
+	case tdeck.DirRight:
+		if g.dir.x == 0 {
 			g.next = vec2{1, 0}
-		} else if dx < 0 && g.dir.x == 0 {
+		}
+	case tdeck.DirLeft:
+		if g.dir.x == 0 {
 			g.next = vec2{-1, 0}
 		}
-	} else {
-		if dy > 0 && g.dir.y == 0 {
+	case tdeck.DirDown:
+		if g.dir.y == 0 {
 			g.next = vec2{0, 1}
-		} else if dy < 0 && g.dir.y == 0 {
+		}
+	case tdeck.DirUp:
+		if g.dir.y == 0 {
 			g.next = vec2{0, -1}
 		}
 	}
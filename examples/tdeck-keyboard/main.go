@@ -26,22 +26,36 @@ func main() {
 		return
 	}
 
-	kb := tdeck.New(i2c, boardPowerOn)
-	kb.PowerOn()
-	time.Sleep(100 * time.Millisecond)
+	// The bus is only read after the keyboard raises its interrupt line.
+	kb := tdeck.NewWithInterrupt(i2c, tdeck.DefaultAddress, boardPowerOn, tdeck.KeyboardINT)
+	if err := kb.PowerOn(); err != nil {
+		println("keyboard:", err.Error())
+	}
 
 	_ = kb.SetBrightness(127)
-	time.Sleep(100 * time.Millisecond)
+	kb.SetAutoOff(10 * time.Second)
 
 	println("Polling keys...")
 	for {
-		code, err := kb.ReadKey()
-		if err != nil {
+		if err := kb.Poll(); err != nil {
+			st := kb.Stats()
+			println("keyboard error:", err.Error(), "errors:", st.Errors, "recoveries:", st.Recoveries)
 			time.Sleep(10 * time.Millisecond)
 			continue
 		}
-		if code != 0 {
-			println("key:", code, "('", string(rune(code)), "')")
+		for {
+			ev, ok := kb.NextEvent()
+			if !ok {
+				break
+			}
+			switch {
+			case ev.Repeat:
+				println("repeat:", tdeck.KeyName(ev.Code))
+			case ev.Pressed:
+				println("key:", tdeck.KeyName(ev.Code), "rune:", string(ev.Rune))
+			default:
+				println("release:", tdeck.KeyName(ev.Code))
+			}
 		}
 		time.Sleep(5 * time.Millisecond)
 	}
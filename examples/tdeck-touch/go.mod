@@ -0,0 +1,12 @@
+module tdeck-touch
+
+go 1.25
+
+replace github.com/dimajolkin/tinygo-lilygo-drivers => ../..
+
+require (
+	github.com/dimajolkin/tinygo-lilygo-drivers v0.0.0-00010101000000-000000000000
+	tinygo.org/x/drivers v0.34.0
+)
+
+require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
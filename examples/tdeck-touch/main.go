@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image/color"
+	"machine"
+	"time"
+
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
+	"github.com/dimajolkin/tinygo-lilygo-drivers/gt911"
+	"github.com/dimajolkin/tinygo-lilygo-drivers/st7789"
+	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/sdcard"
+)
+
+const (
+	TFT_SCLK machine.Pin = 40
+	TFT_MOSI machine.Pin = 41
+	TFT_CS   machine.Pin = 12
+	TFT_DC   machine.Pin = 11
+	TFT_RST  machine.Pin = 10 // он же BOARD_POWERON: питает клавиатуру и тачскрин
+	TFT_BL   machine.Pin = 42
+
+	boardI2CSCL = machine.GPIO8
+	boardI2CSDA = machine.GPIO18
+
+	dotSize  = 4
+	gridStep = 40
+
+	// Калибровка хранится в сырых секторах SD-карты до первого раздела.
+	storeOffset = 64 * 512
+	storeSize   = 2 * 512
+)
+
+var (
+	bgColor    = color.RGBA{0x10, 0x10, 0x20, 255}
+	dotColor   = color.RGBA{0x00, 0xc0, 0xff, 255}
+	gridColor  = color.RGBA{0x40, 0x40, 0x60, 255}
+	crossColor = color.RGBA{0xff, 0xff, 0xff, 255}
+
+	spiDisplay = machine.SPIConfig{
+		Frequency: 80000000,
+		SCK:       TFT_SCLK,
+		SDO:       TFT_MOSI,
+		Mode:      0,
+	}
+)
+
+func main() {
+	time.Sleep(1 * time.Second)
+
+	// SD-карта и дисплей на одной шине SPI: карта настраивает шину под себя,
+	// поэтому после каждого обращения к ней шина перенастраивается для дисплея.
+	TFT_RST.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	TFT_RST.High()
+	time.Sleep(200 * time.Millisecond)
+
+	spi := machine.SPI1
+	sd := sdcard.New(spi, machine.Pin(tdeck.SDCardSCK), machine.Pin(tdeck.SDCardMOSI), machine.Pin(tdeck.SDCardMISO), machine.Pin(tdeck.SDCardCS))
+	var store *lilygo.BlockStore
+	if err := sd.Configure(); err != nil {
+		println("SD:", err.Error(), "- калибровка не сохранится")
+	} else if store, err = lilygo.NewBlockStore(&sd, storeOffset, storeSize); err != nil {
+		println("store:", err.Error())
+	}
+	spi.Configure(spiDisplay)
+
+	display := st7789.New(spi, TFT_RST, TFT_DC, TFT_CS, TFT_BL)
+	display.Configure(st7789.Config{
+		Width:    240,
+		Height:   320,
+		Rotation: drivers.Rotation90,
+	})
+	i2c := machine.I2C0
+	if err := i2c.Configure(machine.I2CConfig{SCL: boardI2CSCL, SDA: boardI2CSDA}); err != nil {
+		println("I2C configure:", err)
+		return
+	}
+
+	// Тачскрин отвечает не сразу после подачи питания.
+	time.Sleep(100 * time.Millisecond)
+	touch := gt911.NewWithAddress(i2c, 0, tdeck.TouchINT)
+	err := touch.Configure(gt911.Config{
+		Width:    240,
+		Height:   320,
+		Rotation: display.Rotation(),
+	})
+	if err != nil {
+		println("touch:", err.Error())
+		return
+	}
+
+	if store == nil || touch.LoadCalibration(store) != nil {
+		display.FillScreen(bgColor)
+		println("Калибровка: коснись каждого крестика.")
+		if _, err := touch.Calibrate(&display, crossColor, bgColor, 30*time.Second); err != nil {
+			println("calibration:", err.Error())
+		} else if store != nil {
+			if err := sd.Configure(); err == nil {
+				err = touch.SaveCalibration(store)
+			}
+			if err != nil {
+				println("save calibration:", err.Error())
+			}
+			spi.Configure(spiDisplay)
+		}
+	}
+	drawGrid(&display)
+
+	gd := gt911.NewGestureDetector()
+	println("Touch: рисуй пальцем, точки должны ложиться на сетку; длинное нажатие очищает экран.")
+	for {
+		points, err := touch.Read()
+		if err != nil {
+			println("touch read:", err.Error())
+		}
+		for _, p := range points {
+			display.FillRectangle(p.X-dotSize/2, p.Y-dotSize/2, dotSize, dotSize, dotColor)
+		}
+		if g, ok := gd.Update(points); ok {
+			switch g.Kind {
+			case gt911.GestureTap:
+				println("tap:", g.X, g.Y)
+			case gt911.GestureLongPress:
+				drawGrid(&display)
+			case gt911.GestureSwipeLeft, gt911.GestureSwipeRight, gt911.GestureSwipeUp, gt911.GestureSwipeDown:
+				println("swipe:", g.DX, g.DY)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// drawGrid рисует сетку как в примере st7789-position, чтобы проверить калибровку.
+func drawGrid(display *st7789.Device) {
+	w, h := display.Size()
+	display.FillScreen(bgColor)
+	for x := int16(0); x < w; x += gridStep {
+		display.FillRectangle(x, 0, 1, h, gridColor)
+	}
+	for y := int16(0); y < h; y += gridStep {
+		display.FillRectangle(0, y, w, 1, gridColor)
+	}
+}
@@ -18,11 +18,10 @@ const (
 	TFT_RST  machine.Pin = 10
 	TFT_BL   machine.Pin = 42
 
-	screenW   = 320
-	screenH   = 240
-	cursorW   = 10
-	cursorH   = 10
-	step      = 4
+	screenW    = 320
+	screenH    = 240
+	cursorW    = 10
+	cursorH    = 10
 	edgeMargin = 2
 )
 
@@ -56,30 +55,22 @@ func main() {
 	display.SetBacklightBrightness(180)
 
 	tb := tdeck.NewTrackballDefault()
-	x := int16(screenW/2 - cursorW/2)
-	y := int16(screenH/2 - cursorH/2)
+	if err := tb.EnableInterrupts(); err != nil {
+		println("trackball interrupts:", err.Error())
+	}
+	cur := tdeck.NewCursor(screenW, screenH)
+	cur.Accel = tdeck.AccelBallistic
+	cur.SetBounds(edgeMargin, edgeMargin, screenW-cursorW-edgeMargin, screenH-cursorH-edgeMargin)
+	cur.SetPosition(screenW/2-cursorW/2, screenH/2-cursorH/2)
+	x, y := cur.Position()
 
 	display.FillScreen(bgColor)
 	display.FillRectangle(x, y, cursorW, cursorH, cursorColor)
 
 	for {
-		dx, dy := tb.ReadMotion() // крути трекбол — импульсы по пинам
 		s := tb.Read()
 		prevX, prevY := x, y
-		x += int16(dx) * step
-		y += int16(dy) * step
-		if x < edgeMargin {
-			x = edgeMargin
-		}
-		if x > screenW-cursorW-edgeMargin {
-			x = screenW - cursorW - edgeMargin
-		}
-		if y < edgeMargin {
-			y = edgeMargin
-		}
-		if y > screenH-cursorH-edgeMargin {
-			y = screenH - cursorH - edgeMargin
-		}
+		x, y = cur.Move(tb.ReadMotion()) // крути трекбол — чем быстрее, тем дальше
 
 		if x != prevX || y != prevY {
 			display.FillRectangle(prevX, prevY, cursorW, cursorH, bgColor)
@@ -3,6 +3,7 @@ package main
 import (
 	"time"
 
+	"github.com/dimajolkin/tinygo-lilygo-drivers/input"
 	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
 )
 
@@ -11,16 +12,25 @@ func main() {
 
 	tb := tdeck.NewTrackballDefault()
 
+	in := input.New(input.Trackball(tb))
+
 	println("Trackball: крути шарик — вывод dx, dy. Нажатие OK = клик.")
 	for {
-		dx, dy := tb.ReadMotion()
-		s := tb.Read()
-		if dx != 0 || dy != 0 {
-			println("motion: dx=", dx, " dy=", dy)
-		}
-		if s.OK {
-			println("OK pressed")
+		ev, _ := in.Next(-1)
+		switch ev.Kind {
+		case input.KindMotion:
+			println("motion: dx=", ev.DX, " dy=", ev.DY)
+		case input.KindButton:
+			switch ev.Button {
+			case tdeck.ButtonClick:
+				println("click")
+			case tdeck.ButtonDoubleClick:
+				println("double click")
+			case tdeck.ButtonLongPress:
+				println("long press")
+			case tdeck.ButtonDrag:
+				println("drag: dx=", ev.DX, " dy=", ev.DY)
+			}
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
 }
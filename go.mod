@@ -2,7 +2,6 @@ module github.com/dimajolkin/tinygo-lilygo-drivers
 
 go 1.25
 
-require (
-	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
-	tinygo.org/x/drivers v0.34.0 // indirect
-)
+require tinygo.org/x/drivers v0.34.0
+
+require github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
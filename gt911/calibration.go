@@ -0,0 +1,257 @@
+package gt911
+
+import (
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"math"
+	"time"
+
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
+	"tinygo.org/x/drivers"
+)
+
+var (
+	errCalibrationPoints  = errors.New("gt911: calibration points are collinear")
+	errCalibrationTimeout = errors.New("gt911: calibration timed out")
+	errCalibrationData    = errors.New("gt911: invalid calibration data")
+)
+
+// CalibrationKey is the key SaveCalibration and LoadCalibration use.
+const CalibrationKey = "gt911.cal"
+
+const calibrationVersion = 1
+
+// Calibration is an affine correction of touch coordinates:
+//
+//	x' = A*x + B*y + C
+//	y' = D*x + E*y + F
+//
+// It works in native panel coordinates, before rotation, so one calibration
+// stays valid for every rotation of the display.
+type Calibration struct {
+	A, B, C float32
+	D, E, F float32
+}
+
+// IdentityCalibration leaves coordinates unchanged.
+var IdentityCalibration = Calibration{A: 1, E: 1}
+
+func (c Calibration) apply(x, y int16) (int16, int16) {
+	fx, fy := float32(x), float32(y)
+	nx := c.A*fx + c.B*fy + c.C
+	ny := c.D*fx + c.E*fy + c.F
+	return int16(nx + 0.5), int16(ny + 0.5)
+}
+
+// CalibrationPoint pairs a position reported by the panel with the position
+// it should have been, both in native coordinates.
+type CalibrationPoint struct {
+	TouchX, TouchY   int16
+	TargetX, TargetY int16
+}
+
+// ComputeCalibration fits a calibration to three or more points by least
+// squares. More points, spread over the panel, average out touch noise.
+func ComputeCalibration(points []CalibrationPoint) (Calibration, error) {
+	// Normal equations of the least squares fit, shared by both rows.
+	var sxx, sxy, syy, sx, sy, n float64
+	var sxu, syu, su, sxv, syv, sv float64
+	for _, p := range points {
+		x, y := float64(p.TouchX), float64(p.TouchY)
+		u, v := float64(p.TargetX), float64(p.TargetY)
+		sxx += x * x
+		sxy += x * y
+		syy += y * y
+		sx += x
+		sy += y
+		n++
+		sxu += x * u
+		syu += y * u
+		su += u
+		sxv += x * v
+		syv += y * v
+		sv += v
+	}
+	m := [3][3]float64{{sxx, sxy, sx}, {sxy, syy, sy}, {sx, sy, n}}
+	det := det3(m)
+	if len(points) < 3 || math.Abs(det) < 1e-6 {
+		return Calibration{}, errCalibrationPoints
+	}
+	a, b, c := solve3(m, det, [3]float64{sxu, syu, su})
+	d, e, f := solve3(m, det, [3]float64{sxv, syv, sv})
+	return Calibration{
+		A: float32(a), B: float32(b), C: float32(c),
+		D: float32(d), E: float32(e), F: float32(f),
+	}, nil
+}
+
+func det3(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// solve3 solves m·x = r by Cramer's rule.
+func solve3(m [3][3]float64, det float64, r [3]float64) (float64, float64, float64) {
+	var x [3]float64
+	for i := range x {
+		mi := m
+		for row := 0; row < 3; row++ {
+			mi[row][i] = r[row]
+		}
+		x[i] = det3(mi) / det
+	}
+	return x[0], x[1], x[2]
+}
+
+// MarshalBinary encodes the calibration for a Store.
+func (c Calibration) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 1, 1+6*4)
+	b[0] = calibrationVersion
+	for _, v := range [6]float32{c.A, c.B, c.C, c.D, c.E, c.F} {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a calibration written by MarshalBinary.
+func (c *Calibration) UnmarshalBinary(b []byte) error {
+	if len(b) != 1+6*4 || b[0] != calibrationVersion {
+		return errCalibrationData
+	}
+	var v [6]float32
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[1+i*4:]))
+	}
+	*c = Calibration{A: v[0], B: v[1], C: v[2], D: v[3], E: v[4], F: v[5]}
+	return nil
+}
+
+// SetCalibration sets the correction applied to every point.
+func (d *Device) SetCalibration(c Calibration) {
+	d.cal = c
+}
+
+// Calibration returns the correction applied to every point.
+func (d *Device) Calibration() Calibration {
+	return d.cal
+}
+
+// SaveCalibration stores the current calibration under CalibrationKey.
+func (d *Device) SaveCalibration(s lilygo.Store) error {
+	b, _ := d.cal.MarshalBinary()
+	return s.Save(CalibrationKey, b)
+}
+
+// LoadCalibration loads and applies a calibration saved with
+// SaveCalibration. It returns lilygo.ErrNotStored if there is none.
+func (d *Device) LoadCalibration(s lilygo.Store) error {
+	var b [1 + 6*4]byte
+	n, err := s.Load(CalibrationKey, b[:])
+	if err != nil {
+		return err
+	}
+	var c Calibration
+	if err := c.UnmarshalBinary(b[:n]); err != nil {
+		return err
+	}
+	d.cal = c
+	return nil
+}
+
+// Drawer is a display Calibrate can draw targets on, such as
+// st7789.Device.
+type Drawer interface {
+	Size() (w, h int16)
+	FillRectangle(x, y, width, height int16, c color.RGBA) error
+}
+
+const (
+	calMargin     = 20 // distance of the corner targets from the edges
+	calCross      = 15 // length of a target's arms
+	calMinSamples = 3
+)
+
+// Calibrate runs an interactive calibration: it draws a cross at five
+// positions, waits for each to be touched and released, then computes and
+// applies the calibration. The caller clears the screen before and after and
+// can save the result with SaveCalibration. Each target waits at most
+// timeout.
+func (d *Device) Calibrate(disp Drawer, fg, bg color.RGBA, timeout time.Duration) (Calibration, error) {
+	old := d.cal
+	d.raw = true
+	defer func() { d.raw = false }()
+
+	w, h := disp.Size()
+	targets := [5][2]int16{
+		{calMargin, calMargin},
+		{w - 1 - calMargin, calMargin},
+		{w - 1 - calMargin, h - 1 - calMargin},
+		{calMargin, h - 1 - calMargin},
+		{w / 2, h / 2},
+	}
+	var points [len(targets)]CalibrationPoint
+	for i, t := range targets {
+		drawCross(disp, t[0], t[1], fg)
+		x, y, err := d.sampleTouch(timeout)
+		drawCross(disp, t[0], t[1], bg)
+		if err != nil {
+			d.cal = old
+			return old, err
+		}
+		nx, ny := d.untransform(t[0], t[1])
+		points[i] = CalibrationPoint{TouchX: x, TouchY: y, TargetX: nx, TargetY: ny}
+	}
+	c, err := ComputeCalibration(points[:])
+	if err != nil {
+		return old, err
+	}
+	d.cal = c
+	return c, nil
+}
+
+// sampleTouch waits for a touch and release and returns the average position
+// while the finger was down.
+func (d *Device) sampleTouch(timeout time.Duration) (int16, int16, error) {
+	deadline := time.Now().Add(timeout)
+	var sx, sy, n int32
+	for time.Now().Before(deadline) {
+		points, err := d.Read()
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case len(points) > 0:
+			sx += int32(points[0].X)
+			sy += int32(points[0].Y)
+			n++
+		case n >= calMinSamples:
+			return int16(sx / n), int16(sy / n), nil
+		default:
+			sx, sy, n = 0, 0, 0 // too short, likely a glitch
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return 0, 0, errCalibrationTimeout
+}
+
+func drawCross(disp Drawer, x, y int16, c color.RGBA) {
+	disp.FillRectangle(x-calCross/2, y, calCross, 1, c)
+	disp.FillRectangle(x, y-calCross/2, 1, calCross, c)
+}
+
+// untransform maps screen coordinates back to native ones, the inverse of
+// the rotation in transform.
+func (d *Device) untransform(x, y int16) (int16, int16) {
+	w, h := d.unrotatedSize()
+	switch d.rotation % 4 {
+	case drivers.Rotation90:
+		return w - 1 - y, x
+	case drivers.Rotation180:
+		return w - 1 - x, h - 1 - y
+	case drivers.Rotation270:
+		return y, h - 1 - x
+	}
+	return x, y
+}
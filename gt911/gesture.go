@@ -0,0 +1,159 @@
+package gt911
+
+import "time"
+
+// GestureKind is the type of a recognised gesture.
+type GestureKind uint8
+
+const (
+	GestureNone GestureKind = iota
+	GestureTap
+	GestureLongPress
+	GestureSwipeLeft
+	GestureSwipeRight
+	GestureSwipeUp
+	GestureSwipeDown
+)
+
+// Gesture is a single-finger gesture. X and Y are where the finger went
+// down, DX and DY how far it moved until it was lifted.
+type Gesture struct {
+	Kind   GestureKind
+	X, Y   int16
+	DX, DY int16
+}
+
+// Default gesture thresholds.
+const (
+	DefaultTapSlop      = 10 // pixels
+	DefaultSwipeMin     = 40 // pixels
+	DefaultSwipeMaxTime = 500 * time.Millisecond
+	DefaultLongPress    = 600 * time.Millisecond
+)
+
+// GestureDetector recognises taps, long presses and swipes of the first
+// finger from successive Read results. It is optional; use the points
+// directly for anything else.
+//
+//	gd := gt911.NewGestureDetector()
+//	for {
+//		points, _ := touch.Read()
+//		if g, ok := gd.Update(points); ok && g.Kind == gt911.GestureTap {
+//			...
+//		}
+//	}
+type GestureDetector struct {
+	// TapSlop is how far a finger may move and still tap or long-press.
+	TapSlop int16
+
+	// SwipeMin is the shortest distance that counts as a swipe.
+	SwipeMin int16
+
+	// SwipeMaxTime is the longest a swipe may take.
+	SwipeMaxTime time.Duration
+
+	// LongPress is how long a finger must rest for GestureLongPress.
+	LongPress time.Duration
+
+	down   bool
+	id     uint8
+	x, y   int16 // start
+	lx, ly int16 // latest
+	since  time.Time
+	moved  bool // left TapSlop
+	long   bool // long press already reported
+}
+
+// NewGestureDetector creates a detector with the default thresholds.
+func NewGestureDetector() *GestureDetector {
+	return &GestureDetector{
+		TapSlop:      DefaultTapSlop,
+		SwipeMin:     DefaultSwipeMin,
+		SwipeMaxTime: DefaultSwipeMaxTime,
+		LongPress:    DefaultLongPress,
+	}
+}
+
+// Update feeds the points of one Read and returns a gesture once one is
+// recognised. Long presses are reported while the finger is still down,
+// taps and swipes when it is lifted.
+func (g *GestureDetector) Update(points []Point) (Gesture, bool) {
+	return g.UpdateAt(points, time.Now())
+}
+
+// UpdateAt is Update with an explicit timestamp.
+func (g *GestureDetector) UpdateAt(points []Point, now time.Time) (Gesture, bool) {
+	var p *Point
+	for i := range points {
+		if !g.down || points[i].ID == g.id {
+			p = &points[i]
+			break
+		}
+	}
+	if p == nil {
+		if !g.down {
+			return Gesture{}, false
+		}
+		g.down = false
+		return g.finish(now)
+	}
+	if !g.down {
+		*g = GestureDetector{
+			TapSlop:      g.TapSlop,
+			SwipeMin:     g.SwipeMin,
+			SwipeMaxTime: g.SwipeMaxTime,
+			LongPress:    g.LongPress,
+			down:         true,
+			id:           p.ID,
+			x:            p.X,
+			y:            p.Y,
+			lx:           p.X,
+			ly:           p.Y,
+			since:        now,
+		}
+		return Gesture{}, false
+	}
+	g.lx, g.ly = p.X, p.Y
+	if abs16(g.lx-g.x) > g.TapSlop || abs16(g.ly-g.y) > g.TapSlop {
+		g.moved = true
+	}
+	if !g.moved && !g.long && now.Sub(g.since) >= g.LongPress {
+		g.long = true
+		return Gesture{Kind: GestureLongPress, X: g.x, Y: g.y}, true
+	}
+	return Gesture{}, false
+}
+
+func (g *GestureDetector) finish(now time.Time) (Gesture, bool) {
+	if g.long {
+		return Gesture{}, false
+	}
+	dx, dy := g.lx-g.x, g.ly-g.y
+	ges := Gesture{X: g.x, Y: g.y, DX: dx, DY: dy}
+	switch {
+	case !g.moved:
+		ges.Kind = GestureTap
+	case now.Sub(g.since) > g.SwipeMaxTime:
+		return Gesture{}, false
+	case abs16(dx) >= abs16(dy) && abs16(dx) >= g.SwipeMin:
+		ges.Kind = GestureSwipeRight
+		if dx < 0 {
+			ges.Kind = GestureSwipeLeft
+		}
+	case abs16(dy) > abs16(dx) && abs16(dy) >= g.SwipeMin:
+		ges.Kind = GestureSwipeDown
+		if dy < 0 {
+			ges.Kind = GestureSwipeUp
+		}
+	default:
+		return Gesture{}, false
+	}
+	return ges, true
+}
+
+func abs16(v int16) int16 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
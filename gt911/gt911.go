@@ -0,0 +1,300 @@
+// Package gt911 implements a driver for the Goodix GT911 capacitive touch
+// controller, as used on the LilyGo T-Deck.
+//
+// On the T-Deck the controller shares the I2C bus with the keyboard and is
+// powered by the same board power pin (GPIO10), which must be high:
+//
+//	touch := gt911.New(i2c, tdeck.TouchINT)
+//	err := touch.Configure(gt911.Config{Width: 240, Height: 320, Rotation: drivers.Rotation90})
+//	for {
+//		points, _ := touch.Read()
+//		for _, p := range points {
+//			println(p.ID, p.X, p.Y)
+//		}
+//	}
+//
+// Datasheet: https://www.crystalfontz.com/controllers/GOODIX/GT911/
+package gt911
+
+import (
+	"errors"
+	"machine"
+	"sync/atomic"
+
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
+	"tinygo.org/x/drivers"
+)
+
+// MaxPoints is the number of touch points the GT911 tracks.
+const MaxPoints = 5
+
+var errNotFound = errors.New("gt911: controller not found")
+
+// Point is a touch point in screen coordinates.
+type Point struct {
+	ID   uint8  // track ID, stable while the finger stays down
+	X, Y int16  // position
+	Size uint16 // contact area
+}
+
+// Config is the configuration for the touch panel.
+//
+// Width and Height are the native resolution of the touch controller, before
+// SwapXY; zero reads them from the controller. For panels mounted like the
+// display they are the same values as in st7789.Config. Points are reported in
+// the coordinates the st7789 driver draws in with the same Rotation, so a
+// touch lands on the pixel under the finger. SwapXY, InvertX and InvertY
+// correct panels that are mounted differently from the display; the T-Deck
+// needs none of them.
+type Config struct {
+	Width    int16
+	Height   int16
+	Rotation drivers.Rotation
+	SwapXY   bool
+	InvertX  bool
+	InvertY  bool
+}
+
+// Device is a GT911 touch controller.
+type Device struct {
+	bus      lilygo.I2C
+	addr     uint16
+	intPin   machine.Pin
+	pending  uint32 // set from the interrupt handler
+	width    int16
+	height   int16
+	rotation drivers.Rotation
+	swapXY   bool
+	invertX  bool
+	invertY  bool
+	cal      Calibration
+	raw      bool // report native coordinates, while calibrating
+	points   [MaxPoints]Point
+	n        int
+	buf      [1 + MaxPoints*pointSize]byte
+	reg      [3]byte
+}
+
+// New creates a new GT911 connection at Address. intPin may be
+// machine.NoPin to read the controller on every call of Read.
+func New(bus lilygo.I2C, intPin machine.Pin) *Device {
+	return NewWithAddress(bus, Address, intPin)
+}
+
+// NewWithAddress creates a new GT911 connection at addr (Address or
+// AddressAlt). An addr of 0 tries both in Configure.
+func NewWithAddress(bus lilygo.I2C, addr uint16, intPin machine.Pin) *Device {
+	return &Device{bus: bus, addr: addr, intPin: intPin, cal: IdentityCalibration}
+}
+
+// Configure checks that the controller answers, reads its resolution if the
+// config leaves it out and sets up the interrupt pin.
+func (d *Device) Configure(cfg Config) error {
+	if d.addr == 0 {
+		d.addr = Address
+		if _, err := d.ProductID(); err != nil {
+			d.addr = AddressAlt
+		}
+	}
+	id, err := d.ProductID()
+	if err != nil {
+		return err
+	}
+	if id != "911" {
+		return errNotFound
+	}
+	d.width, d.height = cfg.Width, cfg.Height
+	if d.width == 0 || d.height == 0 {
+		w, h, err := d.Resolution()
+		if err != nil {
+			return err
+		}
+		d.width, d.height = int16(w), int16(h)
+	}
+	d.rotation = cfg.Rotation
+	d.swapXY = cfg.SwapXY
+	d.invertX = cfg.InvertX
+	d.invertY = cfg.InvertY
+	if d.intPin != machine.NoPin {
+		d.intPin.Configure(machine.PinConfig{Mode: machine.PinInput})
+		err := d.intPin.SetInterrupt(machine.PinFalling, func(machine.Pin) {
+			atomic.StoreUint32(&d.pending, 1)
+		})
+		if err != nil {
+			return err
+		}
+		// Read once even without an interrupt, the first report may have been
+		// missed while configuring.
+		atomic.StoreUint32(&d.pending, 1)
+	}
+	return d.writeReg(regStatus, 0)
+}
+
+// ProductID returns the product ID, "911" for a GT911.
+func (d *Device) ProductID() (string, error) {
+	var b [4]byte
+	if err := d.readReg(regProductID, b[:]); err != nil {
+		return "", err
+	}
+	n := 0
+	for n < len(b) && b[n] != 0 {
+		n++
+	}
+	return string(b[:n]), nil
+}
+
+// FirmwareVersion returns the controller firmware version.
+func (d *Device) FirmwareVersion() (uint16, error) {
+	var b [2]byte
+	err := d.readReg(regFirmware, b[:])
+	return uint16(b[0]) | uint16(b[1])<<8, err
+}
+
+// Resolution returns the resolution the controller reports coordinates in.
+func (d *Device) Resolution() (w, h uint16, err error) {
+	var b [4]byte
+	if err := d.readReg(regXResolution, b[:]); err != nil {
+		return 0, 0, err
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, uint16(b[2]) | uint16(b[3])<<8, nil
+}
+
+// SetResolution changes the resolution in the controller config. The
+// controller stores the config, so this only needs to be done once.
+func (d *Device) SetResolution(w, h uint16) error {
+	var cfg [2 + configSize + 1]byte
+	cfg[0], cfg[1] = regConfig>>8, regConfig&0xFF
+	if err := d.readReg(regConfig, cfg[2:2+configSize]); err != nil {
+		return err
+	}
+	block := cfg[2 : 2+configSize]
+	block[regXResolution-regConfig] = uint8(w)
+	block[regXResolution-regConfig+1] = uint8(w >> 8)
+	block[regYResolution-regConfig] = uint8(h)
+	block[regYResolution-regConfig+1] = uint8(h >> 8)
+	sum := uint8(0)
+	for _, b := range block {
+		sum += b
+	}
+	cfg[len(cfg)-1] = ^sum + 1
+	if err := d.bus.Tx(d.addr, cfg[:], nil); err != nil {
+		return err
+	}
+	d.width, d.height = int16(w), int16(h)
+	return d.writeReg(regConfigFresh, 1)
+}
+
+// Rotation returns the current rotation.
+func (d *Device) Rotation() drivers.Rotation {
+	return d.rotation
+}
+
+// SetRotation changes the rotation, e.g. together with
+// st7789.Device.SetRotation.
+func (d *Device) SetRotation(rotation drivers.Rotation) {
+	d.rotation = rotation
+}
+
+// Size returns the size of the touch area in the current rotation.
+func (d *Device) Size() (w, h int16) {
+	w, h = d.unrotatedSize()
+	if d.rotation == drivers.Rotation0 || d.rotation == drivers.Rotation180 {
+		return w, h
+	}
+	return h, w
+}
+
+// unrotatedSize is the size of the touch area after the mounting corrections
+// and before the rotation, the display's size in Rotation0.
+func (d *Device) unrotatedSize() (w, h int16) {
+	if d.swapXY {
+		return d.height, d.width
+	}
+	return d.width, d.height
+}
+
+// Read returns the current touch points. With an interrupt pin the bus is
+// only read after the controller signalled a new report; otherwise the
+// points of the last report are returned. The slice is only valid until the
+// next call.
+func (d *Device) Read() ([]Point, error) {
+	if d.intPin != machine.NoPin && atomic.SwapUint32(&d.pending, 0) == 0 {
+		return d.points[:d.n], nil
+	}
+	if err := d.readReg(regStatus, d.buf[:1]); err != nil {
+		return nil, err
+	}
+	status := d.buf[0]
+	if status&statusReady == 0 {
+		return d.points[:d.n], nil
+	}
+	n := int(status & statusCount)
+	if n > MaxPoints {
+		n = MaxPoints
+	}
+	if n > 0 {
+		if err := d.readReg(regPoints, d.buf[1:1+n*pointSize]); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < n; i++ {
+		b := d.buf[1+i*pointSize:]
+		x := int16(uint16(b[1]) | uint16(b[2])<<8)
+		y := int16(uint16(b[3]) | uint16(b[4])<<8)
+		x, y = d.transform(x, y)
+		d.points[i] = Point{
+			ID:   b[0],
+			X:    x,
+			Y:    y,
+			Size: uint16(b[5]) | uint16(b[6])<<8,
+		}
+	}
+	d.n = n
+	return d.points[:n], d.writeReg(regStatus, 0)
+}
+
+// Touched reports whether the panel was touched in the last report.
+func (d *Device) Touched() bool {
+	return d.n > 0
+}
+
+// transform maps native panel coordinates to screen coordinates: mounting
+// corrections, then the calibration, then the rotation the same way the
+// st7789 MADCTL settings do.
+func (d *Device) transform(x, y int16) (int16, int16) {
+	if d.swapXY {
+		x, y = y, x
+	}
+	// After the swap x runs along the native Y axis: mirror against the
+	// swapped size.
+	w, h := d.unrotatedSize()
+	if d.invertX {
+		x = w - 1 - x
+	}
+	if d.invertY {
+		y = h - 1 - y
+	}
+	if d.raw {
+		return x, y
+	}
+	x, y = d.cal.apply(x, y)
+	switch d.rotation % 4 {
+	case drivers.Rotation90:
+		return y, w - 1 - x
+	case drivers.Rotation180:
+		return w - 1 - x, h - 1 - y
+	case drivers.Rotation270:
+		return h - 1 - y, x
+	}
+	return x, y
+}
+
+func (d *Device) readReg(reg uint16, buf []byte) error {
+	d.reg[0], d.reg[1] = uint8(reg>>8), uint8(reg)
+	return d.bus.Tx(d.addr, d.reg[:2], buf)
+}
+
+func (d *Device) writeReg(reg uint16, value uint8) error {
+	d.reg[0], d.reg[1], d.reg[2] = uint8(reg>>8), uint8(reg), value
+	return d.bus.Tx(d.addr, d.reg[:3], nil)
+}
@@ -0,0 +1,61 @@
+package gt911
+
+import (
+	"testing"
+
+	"tinygo.org/x/drivers"
+)
+
+// A 320×240 touch controller mounted with swapped axes under a 240×320
+// display.
+func swappedDevice(invertX, invertY bool, rot drivers.Rotation) *Device {
+	return &Device{
+		width: 320, height: 240,
+		swapXY: true, invertX: invertX, invertY: invertY,
+		rotation: rot,
+		cal:      IdentityCalibration,
+	}
+}
+
+func TestTransformSwapInvert(t *testing.T) {
+	tests := []struct {
+		name             string
+		invertX, invertY bool
+		rot              drivers.Rotation
+		x, y             int16 // native
+		wx, wy           int16 // screen
+	}{
+		{"swap", false, false, drivers.Rotation0, 300, 10, 10, 300},
+		{"invertX", true, false, drivers.Rotation0, 0, 0, 239, 0},
+		{"invertX far corner", true, false, drivers.Rotation0, 319, 239, 0, 319},
+		{"invertY", false, true, drivers.Rotation0, 0, 0, 0, 319},
+		{"invertY far corner", false, true, drivers.Rotation0, 319, 239, 239, 0},
+		{"invert both rotated", true, true, drivers.Rotation90, 0, 0, 319, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := swappedDevice(tt.invertX, tt.invertY, tt.rot)
+			x, y := d.transform(tt.x, tt.y)
+			if x != tt.wx || y != tt.wy {
+				t.Fatalf("transform(%d, %d) = (%d, %d), want (%d, %d)", tt.x, tt.y, x, y, tt.wx, tt.wy)
+			}
+			w, h := d.Size()
+			if x < 0 || x >= w || y < 0 || y >= h {
+				t.Fatalf("(%d, %d) outside %d×%d", x, y, w, h)
+			}
+		})
+	}
+}
+
+func TestUntransformInvertsRotation(t *testing.T) {
+	for _, rot := range []drivers.Rotation{drivers.Rotation0, drivers.Rotation90, drivers.Rotation180, drivers.Rotation270} {
+		d := swappedDevice(false, false, rot)
+		for _, p := range [][2]int16{{0, 0}, {239, 0}, {0, 319}, {100, 200}} {
+			// p is in unrotated screen coordinates, i.e. swapped native ones.
+			sx, sy := d.transform(p[1], p[0])
+			if ux, uy := d.untransform(sx, sy); ux != p[0] || uy != p[1] {
+				t.Errorf("rotation %d: %v -> (%d, %d) -> (%d, %d)", rot, p, sx, sy, ux, uy)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package gt911
+
+// I2C addresses. The controller picks one from the INT level while it comes
+// out of reset.
+const (
+	Address    = 0x5D
+	AddressAlt = 0x14
+)
+
+// Registers. Addresses are 16 bits wide, sent high byte first.
+const (
+	regConfig      = 0x8047 // config version, start of the 184-byte config block
+	regXResolution = 0x8048 // X output max, little endian
+	regYResolution = 0x804A // Y output max, little endian
+	regTouchNumber = 0x804C // max touch points, 1-5
+	regModuleSw1   = 0x804D // INT trigger, X2Y, sensor/driver reverse
+	regChecksum    = 0x80FF // two's complement of the config block sum
+	regConfigFresh = 0x8100 // write 1 to apply the config
+	regProductID   = 0x8140 // 4 ASCII bytes, "911\x00"
+	regFirmware    = 0x8144 // little endian
+	regStatus      = 0x814E // bit 7 buffer ready, bits 0-3 point count
+	regPoints      = 0x814F // 8 bytes per point
+)
+
+const (
+	configSize = regChecksum - regConfig // bytes covered by the checksum
+	pointSize  = 8
+
+	statusReady = 0x80
+	statusCount = 0x0F
+)
@@ -0,0 +1,62 @@
+package input
+
+import (
+	"time"
+
+	"github.com/dimajolkin/tinygo-lilygo-drivers/gt911"
+)
+
+type touchSource struct {
+	d    *gt911.Device
+	prev [gt911.MaxPoints]gt911.Point
+	n    int
+}
+
+// TouchPanel returns a source for a GT911 touch panel. Every contact is
+// reported as TouchDown, TouchMove while it moves and TouchUp, identified by
+// the controller's track ID.
+func TouchPanel(d *gt911.Device) Source {
+	return &touchSource{d: d}
+}
+
+func (s *touchSource) Poll(emit func(Event)) error {
+	points, err := s.d.Read()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, p := range s.prev[:s.n] {
+		if !hasPoint(points, p.ID) {
+			emit(touchEvent(p, TouchUp, now))
+		}
+	}
+	for _, p := range points {
+		old, ok := findPoint(s.prev[:s.n], p.ID)
+		switch {
+		case !ok:
+			emit(touchEvent(p, TouchDown, now))
+		case old.X != p.X || old.Y != p.Y:
+			emit(touchEvent(p, TouchMove, now))
+		}
+	}
+	s.n = copy(s.prev[:], points)
+	return nil
+}
+
+func touchEvent(p gt911.Point, phase TouchPhase, now time.Time) Event {
+	return Event{Kind: KindTouch, Time: now, Touch: Touch{ID: p.ID, X: p.X, Y: p.Y, Phase: phase}}
+}
+
+func findPoint(points []gt911.Point, id uint8) (gt911.Point, bool) {
+	for _, p := range points {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return gt911.Point{}, false
+}
+
+func hasPoint(points []gt911.Point, id uint8) bool {
+	_, ok := findPoint(points, id)
+	return ok
+}
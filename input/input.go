@@ -0,0 +1,165 @@
+// Package input merges the T-Deck input devices (keyboard, trackball, touch
+// panel) into one queue of events ordered by time.
+//
+//	in := input.New(input.Keyboard(kb), input.Trackball(tb))
+//	for {
+//		ev, ok := in.Next(100 * time.Millisecond)
+//		if !ok {
+//			continue // timeout
+//		}
+//		switch ev.Kind {
+//		case input.KindKey:
+//			println("key:", tdeck.KeyName(ev.Key.Code))
+//		case input.KindMotion:
+//			x, y = cur.Move(ev.DX, ev.DY)
+//		case input.KindButton:
+//			if ev.Button == tdeck.ButtonClick { ... }
+//		}
+//	}
+package input
+
+import (
+	"time"
+
+	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
+)
+
+// Kind is the type of an input event.
+type Kind uint8
+
+const (
+	KindKey    Kind = iota + 1 // key press, repeat or release, see Event.Key
+	KindMotion                 // trackball rolled, see Event.DX and Event.DY
+	KindButton                 // trackball button gesture, see Event.Button
+	KindTouch                  // touch panel contact, see Event.Touch
+)
+
+// TouchPhase tells where in a contact a touch event belongs.
+type TouchPhase uint8
+
+const (
+	TouchDown TouchPhase = iota + 1
+	TouchMove
+	TouchUp
+)
+
+// Touch is a single touch point in screen coordinates.
+type Touch struct {
+	ID    uint8
+	X, Y  int16
+	Phase TouchPhase
+}
+
+// Event is an event from any input source. Only the fields for its Kind
+// are set.
+type Event struct {
+	Kind   Kind
+	Time   time.Time
+	Key    tdeck.KeyEvent
+	DX, DY int // KindMotion, and KindButton with tdeck.ButtonDrag
+	Button tdeck.ButtonAction
+	Touch  Touch
+}
+
+// Source is an input device. Poll reads the device and passes new events to
+// emit, each with the time it happened.
+type Source interface {
+	Poll(emit func(Event)) error
+}
+
+const (
+	// DefaultPollInterval is how often Next polls the sources while waiting.
+	DefaultPollInterval = 5 * time.Millisecond
+
+	queueSize = 32
+)
+
+// Manager polls a set of sources and hands out their events in time order.
+type Manager struct {
+	// PollInterval is how often Next polls the sources while waiting.
+	PollInterval time.Duration
+
+	sources []Source
+	events  []Event
+	emitFn  func(Event)
+}
+
+// New creates a manager reading the given sources.
+func New(sources ...Source) *Manager {
+	m := &Manager{
+		PollInterval: DefaultPollInterval,
+		sources:      sources,
+		events:       make([]Event, 0, queueSize),
+	}
+	m.emitFn = m.push
+	return m
+}
+
+// Add adds a source.
+func (m *Manager) Add(s Source) {
+	m.sources = append(m.sources, s)
+}
+
+// Poll polls every source once and queues their events. All sources are
+// polled even if one fails; the first error is returned.
+func (m *Manager) Poll() error {
+	var first error
+	for _, s := range m.sources {
+		if err := s.Poll(m.emitFn); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Next returns the oldest queued event. If the queue is empty it polls the
+// sources every PollInterval until an event arrives or timeout passes. A zero
+// timeout polls once, a negative one waits forever. Errors of sources are
+// ignored here; call Poll to see them.
+func (m *Manager) Next(timeout time.Duration) (Event, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(m.events) == 0 {
+			m.Poll()
+		}
+		if len(m.events) > 0 {
+			ev := m.events[0]
+			copy(m.events, m.events[1:])
+			m.events = m.events[:len(m.events)-1]
+			return ev, true
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return Event{}, false
+		}
+		time.Sleep(m.PollInterval)
+	}
+}
+
+// Run sends all events to ch. It never returns; start it in its own
+// goroutine:
+//
+//	events := make(chan input.Event, 8)
+//	go in.Run(events)
+func (m *Manager) Run(ch chan<- Event) {
+	for {
+		ev, _ := m.Next(-1)
+		ch <- ev
+	}
+}
+
+// push inserts ev after all queued events that are not newer, so events from
+// different sources stay in time order and events of one source keep the
+// order they were emitted in. When the queue is full the oldest is dropped.
+func (m *Manager) push(ev Event) {
+	if len(m.events) == cap(m.events) {
+		copy(m.events, m.events[1:])
+		m.events = m.events[:len(m.events)-1]
+	}
+	i := len(m.events)
+	for i > 0 && m.events[i-1].Time.After(ev.Time) {
+		i--
+	}
+	m.events = append(m.events, Event{})
+	copy(m.events[i+1:], m.events[i:])
+	m.events[i] = ev
+}
@@ -0,0 +1,149 @@
+package input
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
+)
+
+// fakeSource emits one batch of events per Poll.
+type fakeSource struct {
+	batches [][]Event
+	err     error
+}
+
+func (f *fakeSource) Poll(emit func(Event)) error {
+	if len(f.batches) > 0 {
+		for _, ev := range f.batches[0] {
+			emit(ev)
+		}
+		f.batches = f.batches[1:]
+	}
+	return f.err
+}
+
+var t0 = time.Unix(0, 0)
+
+func key(code byte, ms int) Event {
+	at := t0.Add(time.Duration(ms) * time.Millisecond)
+	return Event{Kind: KindKey, Time: at, Key: tdeck.KeyEvent{Code: code, Pressed: true, Time: at}}
+}
+
+func motion(dx, ms int) Event {
+	return Event{Kind: KindMotion, Time: t0.Add(time.Duration(ms) * time.Millisecond), DX: dx}
+}
+
+// drain returns the queued events without polling again.
+func drain(m *Manager) []Event {
+	var got []Event
+	for len(m.events) > 0 {
+		ev, _ := m.Next(0)
+		got = append(got, ev)
+	}
+	return got
+}
+
+func TestManagerMergesByTime(t *testing.T) {
+	kb := &fakeSource{batches: [][]Event{{key('a', 10), key('b', 30)}}}
+	tb := &fakeSource{batches: [][]Event{{motion(1, 5), motion(2, 20), motion(3, 40)}}}
+	m := New(kb, tb)
+	m.Poll()
+	got := drain(m)
+	want := []string{"m1", "ka", "m2", "kb", "m3"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i, ev := range got {
+		if s := eventName(ev); s != want[i] {
+			t.Fatalf("event %d = %s, want %s (all: %v)", i, s, want[i], names(got))
+		}
+		if i > 0 && ev.Time.Before(got[i-1].Time) {
+			t.Fatalf("event %d is older than event %d", i, i-1)
+		}
+	}
+}
+
+func TestManagerEqualTimesKeepOrder(t *testing.T) {
+	kb := &fakeSource{batches: [][]Event{{key('x', 10), key('y', 10), key('z', 10)}}}
+	tb := &fakeSource{batches: [][]Event{{motion(1, 10), motion(2, 10)}}}
+	m := New(kb, tb)
+	m.Poll()
+	got := names(drain(m))
+	want := []string{"kx", "ky", "kz", "m1", "m2"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestManagerDropsOldestWhenFull(t *testing.T) {
+	var batch []Event
+	for i := 0; i < queueSize+5; i++ {
+		batch = append(batch, motion(i, i))
+	}
+	m := New(&fakeSource{batches: [][]Event{batch}})
+	m.Poll()
+	got := drain(m)
+	if len(got) != queueSize {
+		t.Fatalf("got %d events, want %d", len(got), queueSize)
+	}
+	if got[0].DX != 5 || got[len(got)-1].DX != queueSize+4 {
+		t.Fatalf("kept events %d..%d, want 5..%d", got[0].DX, got[len(got)-1].DX, queueSize+4)
+	}
+}
+
+func TestManagerLateEventAcrossPolls(t *testing.T) {
+	// A source may report an event older than ones already queued.
+	kb := &fakeSource{batches: [][]Event{{key('a', 30)}, {key('b', 50)}}}
+	tb := &fakeSource{batches: [][]Event{{}, {motion(1, 20)}}}
+	m := New(kb, tb)
+	m.Poll()
+	m.Poll()
+	got := names(drain(m))
+	want := []string{"m1", "ka", "kb"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestManagerPollFirstError(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	b := &fakeSource{batches: [][]Event{{key('b', 1)}}, err: errB}
+	m := New(&fakeSource{err: errA}, b)
+	if err := m.Poll(); err != errA {
+		t.Fatalf("err = %v, want the first source's error", err)
+	}
+	if len(m.events) != 1 {
+		t.Fatal("a failing source stopped the others from being polled")
+	}
+}
+
+func TestManagerNextTimeout(t *testing.T) {
+	m := New(&fakeSource{})
+	if _, ok := m.Next(0); ok {
+		t.Fatal("Next returned an event from an empty source")
+	}
+}
+
+func eventName(ev Event) string {
+	switch ev.Kind {
+	case KindKey:
+		return "k" + string(rune(ev.Key.Code))
+	case KindMotion:
+		return "m" + string(rune('0'+ev.DX))
+	}
+	return "?"
+}
+
+func names(evs []Event) []string {
+	s := make([]string, len(evs))
+	for i, ev := range evs {
+		s[i] = eventName(ev)
+	}
+	return s
+}
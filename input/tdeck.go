@@ -0,0 +1,55 @@
+package input
+
+import (
+	"time"
+
+	"github.com/dimajolkin/tinygo-lilygo-drivers/tdeck"
+)
+
+type keyboardSource struct {
+	kb *tdeck.Keyboard
+}
+
+// Keyboard returns a source for the T-Deck keyboard. Don't set a key handler
+// on kb, it would receive the events instead.
+func Keyboard(kb *tdeck.Keyboard) Source {
+	return keyboardSource{kb}
+}
+
+func (s keyboardSource) Poll(emit func(Event)) error {
+	err := s.kb.Poll()
+	for {
+		ev, ok := s.kb.NextEvent()
+		if !ok {
+			break
+		}
+		emit(Event{Kind: KindKey, Time: ev.Time, Key: ev})
+	}
+	return err
+}
+
+type trackballSource struct {
+	tb *tdeck.Trackball
+}
+
+// Trackball returns a source for the T-Deck trackball, reporting motion and
+// button gestures. Don't set a button handler on tb.
+func Trackball(tb *tdeck.Trackball) Source {
+	return trackballSource{tb}
+}
+
+func (s trackballSource) Poll(emit func(Event)) error {
+	now := time.Now()
+	dx, dy := s.tb.Poll()
+	if dx != 0 || dy != 0 {
+		emit(Event{Kind: KindMotion, Time: now, DX: dx, DY: dy})
+	}
+	for {
+		ev, ok := s.tb.NextButtonEvent()
+		if !ok {
+			break
+		}
+		emit(Event{Kind: KindButton, Time: ev.Time, Button: ev.Action, DX: ev.DX, DY: ev.DY})
+	}
+	return nil
+}
@@ -127,7 +127,10 @@ func (d *DeviceOf[T]) DrawChar(x, y int16, c byte, fg color.RGBA, scale int) {
 
 func (d *DeviceOf[T]) DrawString(x, y int16, s string, fg color.RGBA, scale int) {
 	cw := Font5x7CharWidth(scale)
-	for i, c := range s {
-		d.DrawChar(x+int16(i)*cw, y, byte(c), fg, scale)
+	for _, c := range s {
+		if c < 0x80 {
+			d.DrawChar(x, y, byte(c), fg, scale)
+		}
+		x += cw
 	}
 }
@@ -0,0 +1,94 @@
+package st7789
+
+import "math"
+
+// Gamma holds the positive (PVGAMCTRL) and negative (NVGAMCTRL) voltage gamma
+// tables of the controller.
+type Gamma struct {
+	Positive [14]uint8
+	Negative [14]uint8
+}
+
+// Gamma presets.
+var (
+	// GammaDefault is the table used when Config has no gamma set.
+	GammaDefault = Gamma{
+		Positive: [14]uint8{0xD0, 0x0D, 0x14, 0x0D, 0x0D, 0x09, 0x38, 0x44, 0x4E, 0x3A, 0x17, 0x18, 0x2F, 0x30},
+		Negative: [14]uint8{0xD0, 0x09, 0x0F, 0x08, 0x07, 0x14, 0x37, 0x44, 0x4D, 0x38, 0x15, 0x16, 0x2C, 0x3E},
+	}
+
+	// GammaTFTeSPI is the table from the TFT_eSPI ST7789 init sequence, used
+	// by the LilyGo Arduino examples.
+	GammaTFTeSPI = Gamma{
+		Positive: [14]uint8{0xD0, 0x00, 0x05, 0x0E, 0x15, 0x0D, 0x37, 0x43, 0x47, 0x09, 0x15, 0x12, 0x16, 0x19},
+		Negative: [14]uint8{0xD0, 0x00, 0x05, 0x0D, 0x0C, 0x06, 0x2D, 0x44, 0x40, 0x0E, 0x1C, 0x18, 0x16, 0x19},
+	}
+
+	// GammaHighContrast is a steeper table found in many panel vendor init
+	// sequences. It gives deeper blacks at the cost of shadow detail.
+	GammaHighContrast = Gamma{
+		Positive: [14]uint8{0xD0, 0x04, 0x0D, 0x11, 0x13, 0x2B, 0x3F, 0x54, 0x4C, 0x18, 0x0D, 0x0B, 0x1F, 0x23},
+		Negative: [14]uint8{0xD0, 0x04, 0x0C, 0x11, 0x13, 0x2C, 0x3F, 0x44, 0x51, 0x2F, 0x1F, 0x1F, 0x20, 0x23},
+	}
+)
+
+// Indexes of the mid-tone taps in a gamma table: V20 (7 bits) and V43 (7 bits).
+const (
+	gammaV20 = 6
+	gammaV43 = 8
+)
+
+// GammaFromExponent builds a table for the given display gamma exponent. It
+// starts from GammaDefault, which is taken to be 2.2, and scales the V20 and
+// V43 mid-tone taps by how much the requested curve differs from 2.2 at those
+// gray levels; the end points are left alone. The result is an approximation:
+// use it as a starting point for calibrating a panel by eye.
+func GammaFromExponent(gamma float64) Gamma {
+	g := GammaDefault
+	if gamma <= 0 {
+		return g
+	}
+	for _, tap := range []struct {
+		index int
+		level float64
+	}{{gammaV20, 20}, {gammaV43, 43}} {
+		p := tap.level / 63
+		scale := math.Pow(p, 1/gamma) / math.Pow(p, 1/2.2)
+		g.Positive[tap.index] = scaleGammaTap(g.Positive[tap.index], scale)
+		g.Negative[tap.index] = scaleGammaTap(g.Negative[tap.index], scale)
+	}
+	return g
+}
+
+func scaleGammaTap(v uint8, scale float64) uint8 {
+	s := math.Round(float64(v) * scale)
+	if s < 0 {
+		return 0
+	}
+	if s > 0x7F {
+		return 0x7F
+	}
+	return uint8(s)
+}
+
+// SetGamma loads new gamma tables into the controller. It can be called at any
+// time after Configure, e.g. to calibrate a panel without re-flashing.
+func (d *DeviceOf[T]) SetGamma(g Gamma) error {
+	d.startWrite()
+	err := d.setGamma(g)
+	d.endWrite()
+	return err
+}
+
+func (d *DeviceOf[T]) setGamma(g Gamma) error {
+	d.gamma = g
+	if err := d.sendCommand(GMCTRP1, d.gamma.Positive[:]); err != nil {
+		return err
+	}
+	return d.sendCommand(GMCTRN1, d.gamma.Negative[:])
+}
+
+// Gamma returns the gamma tables currently loaded into the controller.
+func (d *DeviceOf[T]) Gamma() Gamma {
+	return d.gamma
+}
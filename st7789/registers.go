@@ -21,6 +21,8 @@ const (
 	RAMWR      = 0x2C
 	RAMRD      = 0x2E
 	PTLAR      = 0x30
+	IDMOFF     = 0x38
+	IDMON      = 0x39
 	COLMOD     = 0x3A
 	MADCTL     = 0x36
 	MADCTL_MY  = 0x80
@@ -13,6 +13,7 @@ import (
 
 	"errors"
 
+	lilygo "github.com/dimajolkin/tinygo-lilygo-drivers"
 	"tinygo.org/x/drivers"
 	"tinygo.org/x/drivers/pixel"
 )
@@ -37,6 +38,8 @@ type FrameRate uint8
 
 var (
 	errOutOfBounds = errors.New("rectangle coordinates outside display area")
+	errNoRead      = errors.New("transport cannot read from the display")
+	errNoCS        = errors.New("3-wire SPI transport needs a CS pin")
 )
 
 // BacklightPWMSetter is the minimal PWM interface for brightness (Set + Top).
@@ -52,19 +55,20 @@ type BacklightPWM interface {
 	Channel(pin machine.Pin) (uint8, error)
 }
 
-// Device wraps an SPI connection.
+// Device wraps a connection to the display, usually SPI.
 type Device = DeviceOf[pixel.RGB565BE]
 
 // DeviceOf is a generic version of Device. It supports multiple different pixel
 // formats.
 type DeviceOf[T Color] struct {
-	bus             drivers.SPI
-	dcPin           machine.Pin
+	tr              Transport
 	resetPin        machine.Pin
-	csPin           machine.Pin
 	blPin           machine.Pin
 	blPWM           BacklightPWMSetter
 	blChannel       uint8
+	blCurve         lilygo.BrightnessCurve
+	blMinDuty       uint16
+	blLevel         uint8
 	width           int16
 	height          int16
 	columnOffsetCfg int16
@@ -77,7 +81,9 @@ type DeviceOf[T Color] struct {
 	batchData       pixel.Image[T] // "image" with (width, height) of (batchLength, 1)
 	isBGR           bool
 	vSyncLines      int16
-	cmdBuf          [1]byte
+	partial         bool
+	idle            bool
+	gamma           Gamma
 	buf             [6]byte
 }
 
@@ -95,6 +101,11 @@ type Config struct {
 	// to find these values. If not set, the defaults will be used.
 	PVGAMCTRL []uint8 // Positive voltage gamma control (14 bytes)
 	NVGAMCTRL []uint8 // Negative voltage gamma control (14 bytes)
+
+	// Gamma selects both tables at once, e.g. a preset like GammaTFTeSPI or
+	// the result of GammaFromExponent. It takes precedence over PVGAMCTRL and
+	// NVGAMCTRL.
+	Gamma *Gamma
 }
 
 // New creates a new ST7789 connection. The SPI wire must already be configured.
@@ -103,17 +114,29 @@ func New(bus drivers.SPI, resetPin, dcPin, csPin, blPin machine.Pin) Device {
 }
 
 // NewOf creates a new ST7789 connection with a particular pixel format. The SPI
-// wire must already be configured.
+// wire must already be configured. If dcPin is machine.NoPin, the 3-wire
+// (9-bit) SPI transport is used.
 func NewOf[T Color](bus drivers.SPI, resetPin, dcPin, csPin, blPin machine.Pin) DeviceOf[T] {
-	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	if dcPin == machine.NoPin {
+		return NewOfTransport[T](NewSPI3WireTransport(bus, csPin), resetPin, blPin)
+	}
+	return NewOfTransport[T](NewSPITransport(bus, dcPin, csPin), resetPin, blPin)
+}
+
+// NewWithTransport creates a new ST7789 connection over the given transport,
+// e.g. a ParallelTransport for panels on the 8-bit parallel bus.
+func NewWithTransport(t Transport, resetPin, blPin machine.Pin) Device {
+	return NewOfTransport[pixel.RGB565BE](t, resetPin, blPin)
+}
+
+// NewOfTransport creates a new ST7789 connection over the given transport with
+// a particular pixel format.
+func NewOfTransport[T Color](t Transport, resetPin, blPin machine.Pin) DeviceOf[T] {
 	resetPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
-	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	blPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
 	return DeviceOf[T]{
-		bus:      bus,
-		dcPin:    dcPin,
+		tr:       t,
 		resetPin: resetPin,
-		csPin:    csPin,
 		blPin:    blPin,
 	}
 }
@@ -160,6 +183,10 @@ func (d *DeviceOf[T]) Configure(cfg Config) {
 	d.resetPin.High()
 	time.Sleep(50 * time.Millisecond)
 
+	// SWRESET below returns the controller to normal, full color mode.
+	d.partial = false
+	d.idle = false
+
 	d.startWrite()
 	d.sendCommand(SWRESET, nil)
 	d.endWrite()
@@ -190,16 +217,18 @@ func (d *DeviceOf[T]) Configure(cfg Config) {
 	d.sendCommand(FRCTRL2, []byte{byte(d.frameRate)})
 	d.sendCommand(PWCTRL1_D0, []byte{0xa4, 0xa1})
 
-	if len(cfg.PVGAMCTRL) == 14 {
-		d.sendCommand(GMCTRP1, cfg.PVGAMCTRL)
+	gamma := GammaDefault
+	if cfg.Gamma != nil {
+		gamma = *cfg.Gamma
 	} else {
-		d.sendCommand(GMCTRP1, []byte{0xd0, 0x0D, 0x14, 0x0D, 0x0D, 0x09, 0x38, 0x44, 0x4E, 0x3A, 0x17, 0x18, 0x2F, 0x30})
-	}
-	if len(cfg.NVGAMCTRL) == 14 {
-		d.sendCommand(GMCTRN1, cfg.NVGAMCTRL)
-	} else {
-		d.sendCommand(GMCTRN1, []byte{0xd0, 0x09, 0x0F, 0x08, 0x07, 0x14, 0x37, 0x44, 0x4D, 0x38, 0x15, 0x16, 0x2C, 0x3E})
+		if len(cfg.PVGAMCTRL) == 14 {
+			copy(gamma.Positive[:], cfg.PVGAMCTRL)
+		}
+		if len(cfg.NVGAMCTRL) == 14 {
+			copy(gamma.Negative[:], cfg.NVGAMCTRL)
+		}
 	}
+	d.setGamma(gamma)
 
 	d.sendCommand(INVON, nil)
 
@@ -213,18 +242,16 @@ func (d *DeviceOf[T]) Configure(cfg Config) {
 
 	d.endWrite()
 	d.blPin.High()
+	d.blLevel = 255
 }
 
 // Send a command with data to the display. It does not change the chip select
-// pin (it must be low when calling). The DC pin is left high after return,
-// meaning that data can be sent right away.
+// pin (it must be low when calling). Data can be sent right away after
+// return.
 func (d *DeviceOf[T]) sendCommand(command uint8, data []byte) error {
-	d.cmdBuf[0] = command
-	d.dcPin.Low()
-	err := d.bus.Tx(d.cmdBuf[:1], nil)
-	d.dcPin.High()
-	if len(data) != 0 {
-		err = d.bus.Tx(data, nil)
+	err := d.tr.Command(command)
+	if err == nil && len(data) != 0 {
+		err = d.tr.Data(data)
 	}
 	return err
 }
@@ -232,17 +259,13 @@ func (d *DeviceOf[T]) sendCommand(command uint8, data []byte) error {
 // startWrite must be called at the beginning of all exported methods to set the
 // chip select pin low.
 func (d *DeviceOf[T]) startWrite() {
-	if d.csPin != machine.NoPin {
-		d.csPin.Low()
-	}
+	d.tr.Begin()
 }
 
 // endWrite must be called at the end of all exported methods to set the chip
 // select pin high.
 func (d *DeviceOf[T]) endWrite() {
-	if d.csPin != machine.NoPin {
-		d.csPin.High()
-	}
+	d.tr.End()
 }
 
 // getBuffer returns the image buffer, that's always d.batchLength wide and 1
@@ -270,8 +293,13 @@ func (d *DeviceOf[T]) Sync() {
 // NOTE: Use GetHighestScanLine and GetLowestScanLine to obtain the highest
 // and lowest useful values. Values are affected by front and back porch
 // vsync settings (derived from VSyncLines configuration option).
+//
+// It returns right away if the transport cannot read from the display.
 func (d *DeviceOf[T]) SyncToScanLine(scanline uint16) {
-	scan := d.GetScanLine()
+	scan, err := d.getScanLine()
+	if err != nil {
+		return
+	}
 
 	// Sometimes GetScanLine returns erroneous 0 on first call after draw, so double check
 	if scan == 0 {
@@ -301,17 +329,19 @@ func (d *DeviceOf[T]) SyncToScanLine(scanline uint16) {
 
 // GetScanLine reads the current scanline value from the display
 func (d *DeviceOf[T]) GetScanLine() uint16 {
+	scanline, _ := d.getScanLine()
+	return scanline
+}
+
+func (d *DeviceOf[T]) getScanLine() (uint16, error) {
 	d.startWrite()
-	data := []uint8{0x00, 0x00}
-	d.dcPin.Low()
-	d.bus.Transfer(GSCAN)
-	d.dcPin.High()
-	for i := range data {
-		data[i], _ = d.bus.Transfer(0xFF)
-	}
+	data := d.buf[:2]
+	data[0], data[1] = 0, 0
+	d.tr.Command(GSCAN)
+	err := d.tr.Read(data)
 	scanline := uint16(data[0])<<8 + uint16(data[1])
 	d.endWrite()
-	return scanline
+	return scanline, err
 }
 
 // GetHighestScanLine calculates the last scanline id in the frame before VSYNC pause
@@ -373,11 +403,11 @@ func (d *DeviceOf[T]) fillRectangle(x, y, width, height int16, c color.RGBA) err
 	j := int(width) * int(height)
 	for j > 0 {
 		// The DC pin is already set to data in the setWindow call, so we can
-		// just write bytes on the SPI bus.
+		// just write bytes on the bus.
 		if j >= image.Len() {
-			d.bus.Tx(image.RawBuffer(), nil)
+			d.tr.Data(image.RawBuffer())
 		} else {
-			d.bus.Tx(image.Rescale(j, 1).RawBuffer(), nil)
+			d.tr.Data(image.Rescale(j, 1).RawBuffer())
 		}
 		j -= image.Len()
 	}
@@ -395,7 +425,7 @@ func (d *DeviceOf[T]) DrawRGBBitmap8(x, y int16, data []uint8, w, h int16) error
 	}
 	d.startWrite()
 	d.setWindow(x, y, w, h)
-	d.bus.Tx(data, nil)
+	d.tr.Data(data)
 	d.endWrite()
 	return nil
 }
@@ -433,9 +463,9 @@ func (d *DeviceOf[T]) FillRectangleWithBuffer(x, y, width, height int16, buffer
 		// The DC pin is already set to data in the setWindow call, so we don't
 		// have to set it here.
 		if k >= image.Len() {
-			d.bus.Tx(image.RawBuffer(), nil)
+			d.tr.Data(image.RawBuffer())
 		} else {
-			d.bus.Tx(image.Rescale(k, 1).RawBuffer(), nil)
+			d.tr.Data(image.Rescale(k, 1).RawBuffer())
 		}
 		k -= image.Len()
 		offset += image.Len()
@@ -563,15 +593,19 @@ func (d *DeviceOf[T]) ConfigureBacklightPWMChannel(pwm BacklightPWMSetter, chann
 }
 
 // SetBacklightBrightness sets backlight level. 0 = off, 1–255 = brightness.
+// The level is mapped onto the PWM duty through the curve set with
+// SetBacklightCurve (linear by default).
 // If ConfigureBacklightPWM was not called, any non-zero value turns the backlight on (full).
 func (d *DeviceOf[T]) SetBacklightBrightness(level uint8) {
+	d.blLevel = level
 	if d.blPWM != nil {
 		if level == 0 {
 			d.blPWM.Set(d.blChannel, 0)
 			return
 		}
 		top := d.blPWM.Top()
-		d.blPWM.Set(d.blChannel, top*uint32(level)/255)
+		duty := lilygo.BrightnessDuty(d.blCurve, level, d.blMinDuty)
+		d.blPWM.Set(d.blChannel, uint32(uint64(top)*uint64(duty)/lilygo.MaxDuty))
 		return
 	}
 	if level == 0 {
@@ -581,6 +615,27 @@ func (d *DeviceOf[T]) SetBacklightBrightness(level uint8) {
 	}
 }
 
+// BacklightBrightness returns the level last set with SetBacklightBrightness.
+func (d *DeviceOf[T]) BacklightBrightness() uint8 {
+	return d.blLevel
+}
+
+// SetBacklightCurve sets how brightness levels map onto PWM duty, e.g.
+// drivers.CIE1931Brightness for perceptually even steps. minDuty (0..MaxDuty)
+// is the duty used for level 1, so that the lowest levels are still visible.
+// A nil curve restores the linear mapping. The current level is re-applied.
+func (d *DeviceOf[T]) SetBacklightCurve(curve lilygo.BrightnessCurve, minDuty uint16) {
+	d.blCurve = curve
+	d.blMinDuty = minDuty
+	d.SetBacklightBrightness(d.blLevel)
+}
+
+// FadeBacklight animates the backlight from the current level to level over
+// duration. It blocks until the transition is complete.
+func (d *DeviceOf[T]) FadeBacklight(level uint8, duration time.Duration) {
+	lilygo.FadeBrightness(d.blLevel, level, duration, d.SetBacklightBrightness)
+}
+
 // EnableBacklight enables or disables the backlight (on = full brightness when PWM not used).
 func (d *DeviceOf[T]) EnableBacklight(enable bool) {
 	if enable {
@@ -668,9 +723,73 @@ func (d *DeviceOf[T]) SetScroll(line int16) {
 	d.endWrite()
 }
 
-// StopScroll returns the display to its normal state.
+// StopScroll returns the display to its normal state. This also leaves
+// partial mode.
 func (d *DeviceOf[T]) StopScroll() {
 	d.startWrite()
 	d.sendCommand(NORON, nil)
 	d.endWrite()
+	d.partial = false
+}
+
+// SetPartialArea enters partial mode: only panel rows top through bottom
+// (inclusive) are refreshed, the rest of the panel is driven to the
+// non-display color and uses much less power. Rows are counted in the panel's
+// native (unrotated) orientation, 0 to 319. Use ExitPartialMode to return to
+// normal mode.
+func (d *DeviceOf[T]) SetPartialArea(top, bottom int16) error {
+	if top < 0 || bottom < top || bottom >= 320 {
+		return errOutOfBounds
+	}
+	copy(d.buf[:4], []uint8{uint8(top >> 8), uint8(top), uint8(bottom >> 8), uint8(bottom)})
+	d.startWrite()
+	err := d.sendCommand(PTLAR, d.buf[:4])
+	if err == nil {
+		err = d.sendCommand(PTLON, nil)
+	}
+	d.endWrite()
+	if err != nil {
+		return err
+	}
+	d.partial = true
+	return nil
+}
+
+// ExitPartialMode returns the display to normal mode after SetPartialArea.
+func (d *DeviceOf[T]) ExitPartialMode() error {
+	d.startWrite()
+	err := d.sendCommand(NORON, nil)
+	d.endWrite()
+	d.partial = false
+	return err
+}
+
+// PartialMode reports whether the display is in partial mode.
+func (d *DeviceOf[T]) PartialMode() bool {
+	return d.partial
+}
+
+// EnterIdleMode switches the display to idle mode: colors are reduced to 8
+// (the MSB of each component) and the controller lowers its frame rate and
+// drive currents. Useful for always-on clocks and status screens.
+func (d *DeviceOf[T]) EnterIdleMode() error {
+	d.startWrite()
+	err := d.sendCommand(IDMON, nil)
+	d.endWrite()
+	d.idle = true
+	return err
+}
+
+// ExitIdleMode returns the display to full color.
+func (d *DeviceOf[T]) ExitIdleMode() error {
+	d.startWrite()
+	err := d.sendCommand(IDMOFF, nil)
+	d.endWrite()
+	d.idle = false
+	return err
+}
+
+// IdleMode reports whether the display is in idle mode.
+func (d *DeviceOf[T]) IdleMode() bool {
+	return d.idle
 }
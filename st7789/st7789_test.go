@@ -0,0 +1,71 @@
+package st7789
+
+import (
+	"errors"
+	"machine"
+	"testing"
+)
+
+var errFake = errors.New("fake transport error")
+
+// fakeTransport records commands and fails the command failOn.
+type fakeTransport struct {
+	cmds   []uint8
+	failOn uint8
+}
+
+func (f *fakeTransport) Begin() {}
+func (f *fakeTransport) End()   {}
+
+func (f *fakeTransport) Command(cmd uint8) error {
+	f.cmds = append(f.cmds, cmd)
+	if f.failOn != 0 && cmd == f.failOn {
+		return errFake
+	}
+	return nil
+}
+
+func (f *fakeTransport) Data(data []byte) error { return nil }
+func (f *fakeTransport) Read(data []byte) error { return nil }
+
+func TestSetPartialArea(t *testing.T) {
+	tr := &fakeTransport{}
+	d := NewWithTransport(tr, machine.NoPin, machine.NoPin)
+	if err := d.SetPartialArea(40, 79); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.cmds) != 2 || tr.cmds[0] != PTLAR || tr.cmds[1] != PTLON {
+		t.Fatalf("commands %x, want PTLAR PTLON", tr.cmds)
+	}
+	if !d.PartialMode() {
+		t.Fatal("PartialMode false after SetPartialArea")
+	}
+	if err := d.SetPartialArea(80, 79); err != errOutOfBounds {
+		t.Fatalf("bottom above top: err = %v, want errOutOfBounds", err)
+	}
+}
+
+func TestSetPartialAreaError(t *testing.T) {
+	tr := &fakeTransport{failOn: PTLAR}
+	d := NewWithTransport(tr, machine.NoPin, machine.NoPin)
+	if err := d.SetPartialArea(0, 99); err != errFake {
+		t.Fatalf("err = %v, want the PTLAR error", err)
+	}
+	if len(tr.cmds) != 1 {
+		t.Fatalf("commands %x: PTLON sent after PTLAR failed", tr.cmds)
+	}
+	if d.PartialMode() {
+		t.Fatal("PartialMode true after a failed SetPartialArea")
+	}
+}
+
+func TestConfigureResetsModes(t *testing.T) {
+	tr := &fakeTransport{}
+	d := NewWithTransport(tr, machine.NoPin, machine.NoPin)
+	d.SetPartialArea(0, 99)
+	d.EnterIdleMode()
+	d.Configure(Config{Width: 240, Height: 320})
+	if d.PartialMode() || d.IdleMode() {
+		t.Fatalf("after Configure: partial %v, idle %v; want both false", d.PartialMode(), d.IdleMode())
+	}
+}
@@ -0,0 +1,309 @@
+package st7789
+
+import (
+	"machine"
+
+	"tinygo.org/x/drivers"
+)
+
+// Transport carries commands and data to the controller. All drawing code in
+// DeviceOf goes through it, so the same driver works over SPI and over the
+// 8-bit parallel (Intel 8080) bus used by boards like the T-Display-S3.
+type Transport interface {
+	// Begin selects the controller (CS low) for a sequence of transfers.
+	Begin()
+
+	// End deselects the controller (CS high).
+	End()
+
+	// Command sends a command byte with D/C low. Data sent afterwards is
+	// treated as parameters or pixel data for that command.
+	Command(cmd uint8) error
+
+	// Data sends parameters or pixel data with D/C high.
+	Data(data []byte) error
+
+	// Read reads len(data) bytes of the reply to the last command.
+	Read(data []byte) error
+}
+
+// SPITransport is the 4-wire SPI transport: SCK, SDO, CS and a D/C pin.
+type SPITransport struct {
+	bus    drivers.SPI
+	dcPin  machine.Pin
+	csPin  machine.Pin
+	cmdBuf [1]byte
+}
+
+// NewSPITransport creates a transport over an already configured SPI bus. The
+// CS pin may be machine.NoPin if the controller is always selected.
+func NewSPITransport(bus drivers.SPI, dcPin, csPin machine.Pin) *SPITransport {
+	dcPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	return &SPITransport{
+		bus:   bus,
+		dcPin: dcPin,
+		csPin: csPin,
+	}
+}
+
+// Begin sets the chip select pin low.
+func (t *SPITransport) Begin() {
+	if t.csPin != machine.NoPin {
+		t.csPin.Low()
+	}
+}
+
+// End sets the chip select pin high.
+func (t *SPITransport) End() {
+	if t.csPin != machine.NoPin {
+		t.csPin.High()
+	}
+}
+
+// Command sends a command byte. The DC pin is left high after return, meaning
+// that data can be sent right away.
+func (t *SPITransport) Command(cmd uint8) error {
+	t.cmdBuf[0] = cmd
+	t.dcPin.Low()
+	err := t.bus.Tx(t.cmdBuf[:1], nil)
+	t.dcPin.High()
+	return err
+}
+
+// Data sends data bytes.
+func (t *SPITransport) Data(data []byte) error {
+	return t.bus.Tx(data, nil)
+}
+
+// Read clocks in len(data) bytes.
+func (t *SPITransport) Read(data []byte) error {
+	var err error
+	for i := range data {
+		data[i], err = t.bus.Transfer(0xFF)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SPI3WireTransport is the 3-wire SPI transport for panels without a D/C
+// pin: every byte goes out as a 9-bit word whose first bit is the D/C flag.
+// The words are packed into a byte stream for an ordinary 8-bit SPI bus. Each
+// Command or Data call is padded to a whole byte and followed by a CS pulse,
+// which makes the controller drop the incomplete padding word, so the CS pin
+// is required: with machine.NoPin, Command and Data return an error. Reading
+// is not supported.
+type SPI3WireTransport struct {
+	bus   drivers.SPI
+	setCS func(high bool) // csPin.Set, nil without a CS pin
+	acc   uint32          // bits not yet written to buf
+	nbits uint
+	buf   [288]byte // 256 words
+	n     int
+}
+
+// NewSPI3WireTransport creates a 3-wire transport over an already configured
+// SPI bus.
+func NewSPI3WireTransport(bus drivers.SPI, csPin machine.Pin) *SPI3WireTransport {
+	t := &SPI3WireTransport{bus: bus}
+	if csPin != machine.NoPin {
+		csPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
+		csPin.High()
+		t.setCS = csPin.Set
+	}
+	return t
+}
+
+// Begin sets the chip select pin low.
+func (t *SPI3WireTransport) Begin() {
+	if t.setCS != nil {
+		t.setCS(false)
+	}
+}
+
+// End sets the chip select pin high.
+func (t *SPI3WireTransport) End() {
+	if t.setCS != nil {
+		t.setCS(true)
+	}
+}
+
+// Command sends a command word (D/C bit 0).
+func (t *SPI3WireTransport) Command(cmd uint8) error {
+	if t.setCS == nil {
+		return errNoCS
+	}
+	if err := t.push(0, cmd); err != nil {
+		return err
+	}
+	return t.sync()
+}
+
+// Data sends data words (D/C bit 1).
+func (t *SPI3WireTransport) Data(data []byte) error {
+	if t.setCS == nil {
+		return errNoCS
+	}
+	for _, b := range data {
+		if err := t.push(1<<8, b); err != nil {
+			return err
+		}
+	}
+	return t.sync()
+}
+
+// Read is not supported on the 3-wire transport.
+func (t *SPI3WireTransport) Read(data []byte) error {
+	return errNoRead
+}
+
+func (t *SPI3WireTransport) push(dc uint32, b byte) error {
+	t.acc = t.acc<<9 | dc | uint32(b)
+	t.nbits += 9
+	for t.nbits >= 8 {
+		t.nbits -= 8
+		t.buf[t.n] = byte(t.acc >> t.nbits)
+		t.n++
+		if t.n == len(t.buf) {
+			if err := t.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	t.acc &= 1<<t.nbits - 1
+	return nil
+}
+
+// sync pads the last word to a whole byte, sends everything out and pulses CS
+// if padding was added.
+func (t *SPI3WireTransport) sync() error {
+	padded := t.nbits > 0
+	if padded {
+		t.buf[t.n] = byte(t.acc << (8 - t.nbits))
+		t.n++
+		t.acc = 0
+		t.nbits = 0
+	}
+	err := t.flush()
+	if padded {
+		t.setCS(true)
+		t.setCS(false)
+	}
+	return err
+}
+
+func (t *SPI3WireTransport) flush() error {
+	if t.n == 0 {
+		return nil
+	}
+	err := t.bus.Tx(t.buf[:t.n], nil)
+	t.n = 0
+	return err
+}
+
+// ParallelTransport is the 8-bit parallel (Intel 8080) transport, driven by
+// bit-banging GPIOs. Data is latched on the rising edge of WR and read while
+// RD is low.
+type ParallelTransport struct {
+	data  [8]machine.Pin
+	wrPin machine.Pin
+	rdPin machine.Pin
+	dcPin machine.Pin
+	csPin machine.Pin
+}
+
+// NewParallelTransport creates a transport over the data pins D0..D7 and the
+// WR, RD, DC and CS control pins. RD and CS may be machine.NoPin; without RD
+// the transport is write-only.
+//
+// On the LilyGo T-Display-S3 the pins are D0..D7 = 39, 40, 41, 42, 45, 46,
+// 47, 48, WR = 8, RD = 9, DC = 7 and CS = 6.
+func NewParallelTransport(data [8]machine.Pin, wrPin, rdPin, dcPin, csPin machine.Pin) *ParallelTransport {
+	for _, p := range data {
+		p.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	}
+	for _, p := range []machine.Pin{wrPin, rdPin, dcPin, csPin} {
+		if p != machine.NoPin {
+			p.Configure(machine.PinConfig{Mode: machine.PinOutput})
+			p.High()
+		}
+	}
+	return &ParallelTransport{
+		data:  data,
+		wrPin: wrPin,
+		rdPin: rdPin,
+		dcPin: dcPin,
+		csPin: csPin,
+	}
+}
+
+// Begin sets the chip select pin low.
+func (t *ParallelTransport) Begin() {
+	if t.csPin != machine.NoPin {
+		t.csPin.Low()
+	}
+}
+
+// End sets the chip select pin high.
+func (t *ParallelTransport) End() {
+	if t.csPin != machine.NoPin {
+		t.csPin.High()
+	}
+}
+
+// Command sends a command byte. The DC pin is left high after return.
+func (t *ParallelTransport) Command(cmd uint8) error {
+	t.dcPin.Low()
+	t.write(cmd)
+	t.dcPin.High()
+	return nil
+}
+
+// Data sends data bytes.
+func (t *ParallelTransport) Data(data []byte) error {
+	for _, b := range data {
+		t.write(b)
+	}
+	return nil
+}
+
+// Read reads len(data) bytes. The dummy byte the controller sends first on the
+// parallel bus is skipped.
+func (t *ParallelTransport) Read(data []byte) error {
+	if t.rdPin == machine.NoPin {
+		return errNoRead
+	}
+	for _, p := range t.data {
+		p.Configure(machine.PinConfig{Mode: machine.PinInput})
+	}
+	t.read()
+	for i := range data {
+		data[i] = t.read()
+	}
+	for _, p := range t.data {
+		p.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	}
+	return nil
+}
+
+func (t *ParallelTransport) write(b byte) {
+	for i, p := range t.data {
+		p.Set(b&(1<<i) != 0)
+	}
+	t.wrPin.Low()
+	t.wrPin.High()
+}
+
+func (t *ParallelTransport) read() byte {
+	t.rdPin.Low()
+	var b byte
+	for i, p := range t.data {
+		if p.Get() {
+			b |= 1 << i
+		}
+	}
+	t.rdPin.High()
+	return b
+}
@@ -0,0 +1,127 @@
+package st7789
+
+import (
+	"bytes"
+	"fmt"
+	"machine"
+	"testing"
+)
+
+// fakeSPI records bus writes and CS changes in order.
+type fakeSPI struct {
+	log []string
+	txs [][]byte
+}
+
+func (f *fakeSPI) Tx(w, r []byte) error {
+	f.txs = append(f.txs, append([]byte(nil), w...))
+	f.log = append(f.log, fmt.Sprintf("tx %x", w))
+	return nil
+}
+
+func (f *fakeSPI) Transfer(b byte) (byte, error) {
+	f.log = append(f.log, fmt.Sprintf("tx %02x", b))
+	return 0, nil
+}
+
+func (f *fakeSPI) written() []byte {
+	return bytes.Join(f.txs, nil)
+}
+
+func newTest3Wire() (*SPI3WireTransport, *fakeSPI) {
+	bus := &fakeSPI{}
+	t := NewSPI3WireTransport(bus, machine.NoPin)
+	t.setCS = func(high bool) {
+		if high {
+			bus.log = append(bus.log, "cs high")
+		} else {
+			bus.log = append(bus.log, "cs low")
+		}
+	}
+	return t, bus
+}
+
+func Test3WirePacking(t *testing.T) {
+	tests := []struct {
+		name string
+		send func(*SPI3WireTransport) error
+		want []byte
+	}{
+		{
+			// 0_00101010 -> 00010101 0(0000000)
+			name: "command",
+			send: func(tr *SPI3WireTransport) error { return tr.Command(0x2A) },
+			want: []byte{0x15, 0x00},
+		},
+		{
+			// 1_00010010 1_00110100 -> 10001001 01001101 00(000000)
+			name: "data",
+			send: func(tr *SPI3WireTransport) error { return tr.Data([]byte{0x12, 0x34}) },
+			want: []byte{0x89, 0x4D, 0x00},
+		},
+		{
+			// Eight words are exactly nine bytes: no padding.
+			name: "aligned",
+			send: func(tr *SPI3WireTransport) error { return tr.Data(make([]byte, 8)) },
+			want: []byte{0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, bus := newTest3Wire()
+			if err := tt.send(tr); err != nil {
+				t.Fatal(err)
+			}
+			if got := bus.written(); !bytes.Equal(got, tt.want) {
+				t.Errorf("wrote %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test3WireFlushAcrossBuffer(t *testing.T) {
+	tr, bus := newTest3Wire()
+	// 300 words of all ones: 2700 bits, 337 whole bytes and 4 padded bits.
+	data := bytes.Repeat([]byte{0xFF}, 300)
+	if err := tr.Data(data); err != nil {
+		t.Fatal(err)
+	}
+	want := append(bytes.Repeat([]byte{0xFF}, 337), 0xF0)
+	if got := bus.written(); !bytes.Equal(got, want) {
+		t.Fatalf("wrote %d bytes %x..., want %d bytes", len(got), got[len(got)-2:], len(want))
+	}
+	if len(bus.txs) != 2 || len(bus.txs[0]) != len(tr.buf) {
+		t.Fatalf("transactions %d, first %d bytes; want 2, first %d", len(bus.txs), len(bus.txs[0]), len(tr.buf))
+	}
+}
+
+func Test3WireCSFraming(t *testing.T) {
+	tr, bus := newTest3Wire()
+	tr.Begin()
+	tr.Command(0x2C)
+	tr.Data(make([]byte, 8)) // aligned: no CS pulse
+	tr.Data([]byte{0x01})
+	tr.End()
+	want := []string{
+		"cs low",
+		"tx 1600", "cs high", "cs low",
+		"tx 804020100804020100",
+		"tx 8080", "cs high", "cs low",
+		"cs high",
+	}
+	if fmt.Sprint(bus.log) != fmt.Sprint(want) {
+		t.Fatalf("log\n%q\nwant\n%q", bus.log, want)
+	}
+}
+
+func Test3WireNeedsCS(t *testing.T) {
+	tr := NewSPI3WireTransport(&fakeSPI{}, machine.NoPin)
+	tr.Begin()
+	if err := tr.Command(0x2C); err != errNoCS {
+		t.Fatalf("Command: %v, want errNoCS", err)
+	}
+	if err := tr.Data([]byte{1}); err != errNoCS {
+		t.Fatalf("Data: %v, want errNoCS", err)
+	}
+	tr.End()
+}
@@ -0,0 +1,210 @@
+package drivers
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var (
+	// ErrNotStored is returned by Store.Load for a key that was never saved.
+	ErrNotStored = errors.New("drivers: key not stored")
+
+	errStoreFull   = errors.New("drivers: store full")
+	errStoreRegion = errors.New("drivers: store region not aligned to erase blocks")
+)
+
+// Store keeps small settings blobs, such as calibration data, across resets.
+type Store interface {
+	// Load copies the data saved under key into buf and returns its length.
+	Load(key string, buf []byte) (int, error)
+
+	// Save stores data under key, replacing earlier data.
+	Save(key string, data []byte) error
+}
+
+// BlockDevice is storage that is erased and written in blocks. It is
+// implemented by machine.Flash on targets that have it and by the SD card
+// driver from tinygo.org/x/drivers/sdcard.
+type BlockDevice interface {
+	ReadAt(p []byte, off int64) (n int, err error)
+	WriteAt(p []byte, off int64) (n int, err error)
+	Size() int64
+	WriteBlockSize() int64
+	EraseBlockSize() int64
+	EraseBlocks(start, len int64) error
+}
+
+var storeMagic = [4]byte{'L', 'G', 'S', 'T'}
+
+// storeHeader is the magic, the sequence number, the length of the records
+// and the CRC-32 of the sequence number, length and records.
+const storeHeader = 16
+
+// BlockStore is a Store in a reserved region of a block device. The region
+// is split into two slots, each holding a header and a list of key/value
+// records. Save writes the whole list to the slot not in use and Load reads
+// the valid slot with the newer sequence number, so a Save cut short by a
+// reset or brown-out leaves the previous contents intact. Keep the region
+// small (two erase blocks are plenty) and don't save in a loop.
+//
+// The region must not overlap anything else on the device. On an SD card
+// that also holds a filesystem, the sectors before the first partition
+// (usually 1-2047) are free on most cards.
+type BlockStore struct {
+	dev    BlockDevice
+	offset int64
+	slot   int64 // size of one slot
+}
+
+// NewBlockStore creates a store in size bytes of dev starting at offset. Both
+// must be multiples of the erase block size, and size must hold at least two
+// erase blocks.
+func NewBlockStore(dev BlockDevice, offset, size int64) (*BlockStore, error) {
+	eb := dev.EraseBlockSize()
+	if eb <= 0 || offset%eb != 0 || size%eb != 0 || size < 2*eb || offset+size > dev.Size() {
+		return nil, errStoreRegion
+	}
+	slot := size / eb / 2 * eb
+	if slot < storeHeader {
+		return nil, errStoreRegion
+	}
+	return &BlockStore{dev: dev, offset: offset, slot: slot}, nil
+}
+
+// Load copies the data saved under key into buf and returns its length. It
+// returns ErrNotStored if the key is missing or the region is empty or
+// corrupt.
+func (s *BlockStore) Load(key string, buf []byte) (int, error) {
+	recs, _, _, err := s.read()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := findRecord(recs, key)
+	if !ok {
+		return 0, ErrNotStored
+	}
+	return copy(buf, v), nil
+}
+
+// Save stores data under key. Keys are at most 255 bytes, data at most
+// 65535 bytes.
+func (s *BlockStore) Save(key string, data []byte) error {
+	if len(key) == 0 || len(key) > 0xFF || len(data) > 0xFFFF {
+		return errStoreFull
+	}
+	recs, cur, seq, err := s.read()
+	if err != nil {
+		return err
+	}
+	out := make([]byte, storeHeader, s.slot)
+	for len(recs) > 0 {
+		k, v, rest := nextRecord(recs)
+		if k != "" && k != key {
+			out = appendRecord(out, k, v)
+		}
+		recs = rest
+	}
+	out = appendRecord(out, key, data)
+	if int64(len(out)) > s.slot {
+		return errStoreFull
+	}
+	copy(out, storeMagic[:])
+	binary.LittleEndian.PutUint32(out[4:], seq+1)
+	binary.LittleEndian.PutUint32(out[8:], uint32(len(out)-storeHeader))
+	binary.LittleEndian.PutUint32(out[12:], storeCRC(out[4:12], out[storeHeader:]))
+
+	if wb := s.dev.WriteBlockSize(); wb > 1 {
+		for int64(len(out))%wb != 0 {
+			out = append(out, 0xFF)
+		}
+	}
+	// Write to the other slot: the current one stays valid until this
+	// write is complete.
+	off := s.offset + (1-cur)*s.slot
+	eb := s.dev.EraseBlockSize()
+	if err := s.dev.EraseBlocks(off/eb, s.slot/eb); err != nil {
+		return err
+	}
+	_, err = s.dev.WriteAt(out, off)
+	return err
+}
+
+// read returns the records of the newer valid slot, that slot and its
+// sequence number. With no valid slot it returns no records, slot 1 and
+// sequence 0, so the next Save goes to slot 0.
+func (s *BlockStore) read() (recs []byte, slot int64, seq uint32, err error) {
+	slot = 1
+	found := false
+	for i := int64(0); i < 2; i++ {
+		r, n, ok, err := s.readSlot(i)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		// Sequence numbers are compared with wraparound.
+		if ok && (!found || int32(n-seq) > 0) {
+			recs, slot, seq, found = r, i, n, true
+		}
+	}
+	return recs, slot, seq, nil
+}
+
+// readSlot returns the records and sequence number of slot i, or ok false
+// if it holds no valid data.
+func (s *BlockStore) readSlot(i int64) (recs []byte, seq uint32, ok bool, err error) {
+	off := s.offset + i*s.slot
+	var hdr [storeHeader]byte
+	if _, err := s.dev.ReadAt(hdr[:], off); err != nil {
+		return nil, 0, false, err
+	}
+	n := int64(binary.LittleEndian.Uint32(hdr[8:]))
+	if [4]byte(hdr[:4]) != storeMagic || n > s.slot-storeHeader {
+		return nil, 0, false, nil
+	}
+	recs = make([]byte, n)
+	if _, err := s.dev.ReadAt(recs, off+storeHeader); err != nil {
+		return nil, 0, false, err
+	}
+	if storeCRC(hdr[4:12], recs) != binary.LittleEndian.Uint32(hdr[12:]) {
+		return nil, 0, false, nil
+	}
+	return recs, binary.LittleEndian.Uint32(hdr[4:]), true, nil
+}
+
+func storeCRC(hdr, recs []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(hdr), crc32.IEEETable, recs)
+}
+
+// A record is the key length (1 byte), the key, the data length (2 bytes,
+// little endian) and the data.
+func appendRecord(b []byte, key string, data []byte) []byte {
+	b = append(b, uint8(len(key)))
+	b = append(b, key...)
+	b = append(b, uint8(len(data)), uint8(len(data)>>8))
+	return append(b, data...)
+}
+
+func nextRecord(b []byte) (key string, data, rest []byte) {
+	kl := int(b[0])
+	if 1+kl+2 > len(b) {
+		return "", nil, nil
+	}
+	key = string(b[1 : 1+kl])
+	b = b[1+kl:]
+	dl := int(b[0]) | int(b[1])<<8
+	if 2+dl > len(b) {
+		return "", nil, nil
+	}
+	return key, b[2 : 2+dl], b[2+dl:]
+}
+
+func findRecord(b []byte, key string) ([]byte, bool) {
+	for len(b) > 0 && key != "" {
+		k, v, rest := nextRecord(b)
+		if k == key {
+			return v, true
+		}
+		b = rest
+	}
+	return nil, false
+}
@@ -0,0 +1,255 @@
+package drivers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// memDevice is an in-memory BlockDevice that checks write alignment.
+type memDevice struct {
+	data    []byte
+	wb, eb  int64
+	badSize bool // a write was not a multiple of wb
+}
+
+func newMemDevice(size, wb, eb int64) *memDevice {
+	d := &memDevice{data: make([]byte, size), wb: wb, eb: eb}
+	for i := range d.data {
+		d.data[i] = 0xFF
+	}
+	return d
+}
+
+func (d *memDevice) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > int64(len(d.data)) {
+		return 0, errors.New("read past end")
+	}
+	return copy(p, d.data[off:]), nil
+}
+
+func (d *memDevice) WriteAt(p []byte, off int64) (int, error) {
+	if int64(len(p))%d.wb != 0 || off%d.wb != 0 {
+		d.badSize = true
+	}
+	if off+int64(len(p)) > int64(len(d.data)) {
+		return 0, errors.New("write past end")
+	}
+	return copy(d.data[off:], p), nil
+}
+
+func (d *memDevice) Size() int64           { return int64(len(d.data)) }
+func (d *memDevice) WriteBlockSize() int64 { return d.wb }
+func (d *memDevice) EraseBlockSize() int64 { return d.eb }
+
+func (d *memDevice) EraseBlocks(start, n int64) error {
+	for i := start * d.eb; i < (start+n)*d.eb; i++ {
+		d.data[i] = 0xFF
+	}
+	return nil
+}
+
+func newTestStore(t *testing.T, d *memDevice) *BlockStore {
+	t.Helper()
+	s, err := NewBlockStore(d, d.eb, 2*d.eb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func load(t *testing.T, s Store, key string) []byte {
+	t.Helper()
+	buf := make([]byte, 1024)
+	n, err := s.Load(key, buf)
+	if err != nil {
+		t.Fatalf("Load(%q): %v", key, err)
+	}
+	return buf[:n]
+}
+
+func TestBlockStoreRoundTrip(t *testing.T) {
+	s := newTestStore(t, newMemDevice(4096, 1, 512))
+	vals := map[string][]byte{
+		"touch.cal":   {1, 2, 3, 4},
+		"battery.cal": {5, 6},
+		"empty":       {},
+	}
+	for k, v := range vals {
+		if err := s.Save(k, v); err != nil {
+			t.Fatalf("Save(%q): %v", k, err)
+		}
+	}
+	for k, v := range vals {
+		if got := load(t, s, k); !bytes.Equal(got, v) {
+			t.Errorf("Load(%q) = %v, want %v", k, got, v)
+		}
+	}
+	if _, err := s.Load("missing", nil); err != ErrNotStored {
+		t.Errorf("Load(missing): err = %v, want ErrNotStored", err)
+	}
+}
+
+func TestBlockStoreReplace(t *testing.T) {
+	s := newTestStore(t, newMemDevice(4096, 1, 512))
+	s.Save("a", []byte{1})
+	s.Save("b", []byte{2})
+	if err := s.Save("a", []byte{3, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := load(t, s, "a"); !bytes.Equal(got, []byte{3, 3}) {
+		t.Errorf("a = %v, want [3 3]", got)
+	}
+	if got := load(t, s, "b"); !bytes.Equal(got, []byte{2}) {
+		t.Errorf("b = %v, want [2]", got)
+	}
+	recs, _, _, _ := s.read()
+	if want := 2 + 2 + 1 + 2 + 2 + 2; len(recs) != want {
+		t.Errorf("records take %d bytes, want %d: the old a was kept", len(recs), want)
+	}
+}
+
+func TestBlockStoreFull(t *testing.T) {
+	s := newTestStore(t, newMemDevice(4096, 1, 512))
+	if err := s.Save("big", make([]byte, 512)); err != errStoreFull {
+		t.Fatalf("oversized Save: err = %v, want errStoreFull", err)
+	}
+	if err := s.Save("", []byte{1}); err != errStoreFull {
+		t.Fatalf("empty key: err = %v, want errStoreFull", err)
+	}
+	s.Save("a", make([]byte, 400))
+	if err := s.Save("b", make([]byte, 100)); err != errStoreFull {
+		t.Fatalf("Save past the slot: err = %v, want errStoreFull", err)
+	}
+	if got := load(t, s, "a"); len(got) != 400 {
+		t.Fatalf("a lost after a failed Save: %d bytes", len(got))
+	}
+}
+
+func TestBlockStoreWriteBlockPadding(t *testing.T) {
+	d := newMemDevice(4096, 16, 512)
+	s := newTestStore(t, d)
+	for _, n := range []int{0, 1, 15, 33} {
+		if err := s.Save("k", make([]byte, n)); err != nil {
+			t.Fatal(err)
+		}
+		if got := load(t, s, "k"); len(got) != n {
+			t.Fatalf("Load after Save of %d bytes: %d bytes", n, len(got))
+		}
+	}
+	if d.badSize {
+		t.Fatal("write not padded to the write block size")
+	}
+}
+
+func TestBlockStoreCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		off  int64 // from the start of the first slot
+	}{
+		{"magic", 0},
+		{"sequence", 4},
+		{"length", 8},
+		{"crc", 12},
+		{"record", storeHeader + 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newMemDevice(4096, 1, 512)
+			s := newTestStore(t, d)
+			s.Save("key", []byte{1, 2, 3})
+			d.data[s.offset+tt.off] ^= 0x5A
+			if _, err := s.Load("key", make([]byte, 8)); err != ErrNotStored {
+				t.Fatalf("err = %v, want ErrNotStored", err)
+			}
+		})
+	}
+}
+
+func TestBlockStoreInterruptedSave(t *testing.T) {
+	d := newMemDevice(4096, 1, 512)
+	s := newTestStore(t, d)
+	s.Save("key", []byte{1})
+	s.Save("key", []byte{2})
+	// The next Save erases the slot with [1]; a reset during its write
+	// leaves that slot invalid and must not lose [2].
+	_, cur, _, _ := s.read()
+	other := s.offset + (1-cur)*s.slot
+	d.data[other] = 0xFF
+	if got := load(t, s, "key"); !bytes.Equal(got, []byte{2}) {
+		t.Fatalf("key = %v, want [2]", got)
+	}
+	d.data[s.offset+cur*s.slot] ^= 0x5A
+	if _, err := s.Load("key", nil); err != ErrNotStored {
+		t.Fatalf("both slots broken: err = %v, want ErrNotStored", err)
+	}
+}
+
+func TestBlockStoreFallback(t *testing.T) {
+	d := newMemDevice(4096, 1, 512)
+	s := newTestStore(t, d)
+	s.Save("key", []byte{1})
+	s.Save("key", []byte{2})
+	_, cur, _, _ := s.read()
+	d.data[s.offset+cur*s.slot+storeHeader] ^= 0x5A
+	if got := load(t, s, "key"); !bytes.Equal(got, []byte{1}) {
+		t.Fatalf("key = %v with the newest slot broken, want [1]", got)
+	}
+}
+
+func TestBlockStoreSequenceWrap(t *testing.T) {
+	s := newTestStore(t, newMemDevice(4096, 1, 512))
+	s.Save("key", []byte{1})
+	// Pretend the sequence is about to wrap around.
+	_, cur, _, _ := s.read()
+	hdr := s.dev.(*memDevice).data[s.offset+cur*s.slot:]
+	binary.LittleEndian.PutUint32(hdr[4:], 0xFFFFFFFF)
+	n := binary.LittleEndian.Uint32(hdr[8:])
+	binary.LittleEndian.PutUint32(hdr[12:], storeCRC(hdr[4:12], hdr[storeHeader:storeHeader+n]))
+	if _, _, seq, _ := s.read(); seq != 0xFFFFFFFF {
+		t.Fatalf("sequence = %#x, want 0xffffffff", seq)
+	}
+	if err := s.Save("key", []byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, slot, seq, _ := s.read(); slot == cur || seq != 0 {
+		t.Fatalf("after the wrap: slot %d, sequence %d; want slot %d, sequence 0", slot, seq, 1-cur)
+	}
+	if got := load(t, s, "key"); !bytes.Equal(got, []byte{2}) {
+		t.Fatalf("key = %v after the sequence wrapped, want [2]", got)
+	}
+}
+
+func TestNextRecordTruncated(t *testing.T) {
+	recs := appendRecord(nil, "alpha", []byte{1, 2, 3})
+	recs = appendRecord(recs, "beta", []byte{4})
+	for n := 1; n < len(recs); n++ {
+		b := recs[:n]
+		// Walking a truncated list must end without a panic.
+		for i := 0; len(b) > 0; i++ {
+			if i > len(recs) {
+				t.Fatalf("%d bytes: walk does not end", n)
+			}
+			_, _, b = nextRecord(b)
+		}
+		v, ok := findRecord(recs[:n], "beta")
+		if ok && !bytes.Equal(v, []byte{4}) {
+			t.Fatalf("%d bytes: beta = %v", n, v)
+		}
+	}
+}
+
+func TestNewBlockStoreRegion(t *testing.T) {
+	d := newMemDevice(4096, 1, 512)
+	for _, r := range []struct{ off, size int64 }{
+		{0, 512},    // one erase block: no room for two slots
+		{100, 1024}, // not aligned
+		{0, 1000},   // not a multiple of the erase block
+		{3584, 1024},
+	} {
+		if _, err := NewBlockStore(d, r.off, r.size); err != errStoreRegion {
+			t.Errorf("NewBlockStore(%d, %d): err = %v, want errStoreRegion", r.off, r.size, err)
+		}
+	}
+}
@@ -2,18 +2,14 @@ package tdeck
 
 import (
 	"machine"
-	"strconv"
 	"time"
+
+	drivers "github.com/dimajolkin/tinygo-lilygo-drivers"
 )
 
 const (
-	adcBits         = 16
-	adcMax          = (1 << adcBits) - 1 // на ESP32-S3 в TinyGo сырые значения 0..65535 (наблюдаемо до ~65520)
-	adcRefMV        = 3300
-	smoothAlpha     = 0.12 // при разряде — меньше дрожания
-	smoothAlphaChg  = 0.45 // при зарядке — быстрее виден рост напряжения
-	rawAdcChgEnter  = 62000 // зарядка: сырой АЦП достиг этого значения (близко к макс 65520)
-	rawAdcChgExit   = 55000 // выход из зарядки: сырой АЦП ниже этого (гистерезис)
+	smoothAlpha    = 0.12 // при разряде — меньше дрожания
+	smoothAlphaChg = 0.45 // при зарядке — быстрее виден рост напряжения
 )
 
 type SoCMethod int
@@ -29,6 +25,25 @@ type BatteryConfig struct {
 	ChargedMV int32
 	Divider   int32
 	SoC       SoCMethod
+
+	// ADCAttenuation — ослабление входа АЦП; ADCSamples отсчётов на одно
+	// измерение объединяются фильтром ADCFilter.
+	ADCAttenuation ADCAttenuation
+	ADCSamples     uint8
+	ADCFilter      ADCFilter
+
+	// InternalResistanceMOhm — внутреннее сопротивление батареи, мОм. Вместе
+	// с Loads даёт поправку напряжения под нагрузкой; 0 — без компенсации.
+	InternalResistanceMOhm int32
+	Loads                  BatteryLoads
+
+	// CapacityMAh — ёмкость батареи для оценки времени; 0 — без оценки.
+	// ChargeMA — ток заряда зарядника в фазе постоянного тока.
+	CapacityMAh int32
+	ChargeMA    int32
+
+	// Store — где хранится калибровка (см. StartCalibration); nil — без неё.
+	Store drivers.Store
 }
 
 func DefaultBatteryConfig() BatteryConfig {
@@ -38,38 +53,26 @@ func DefaultBatteryConfig() BatteryConfig {
 		ChargedMV: 4200,
 		Divider:   2,
 		SoC:       SoCLiIon,
+
+		ADCSamples: defaultADCSamples,
+		ADCFilter:  ADCTrimmedMean,
+
+		InternalResistanceMOhm: defaultInternalResistanceMOhm,
+		Loads:                  DefaultBatteryLoads(),
+		CapacityMAh:            defaultCapacityMAh,
+		ChargeMA:               defaultChargeMA,
 	}
 }
 
 // Типовая разрядная кривая Li-ion (одна ячейка), напряжение мВ -> условный % (0–100 для 3000–4200 мВ).
-var liIonCurve = []struct {
-	mv  int32
-	pct int
-}{
+var liIonCurve = []CurvePoint{
 	{3000, 0}, {3300, 2}, {3400, 5}, {3520, 10}, {3600, 15}, {3640, 20},
 	{3680, 30}, {3720, 40}, {3780, 50}, {3850, 60}, {3920, 70}, {4000, 80},
 	{4080, 90}, {4150, 97}, {4200, 100},
 }
 
 func liIonCurvePct(mv int32) int {
-	if mv <= liIonCurve[0].mv {
-		return 0
-	}
-	if mv >= liIonCurve[len(liIonCurve)-1].mv {
-		return 100
-	}
-	for i := 0; i < len(liIonCurve)-1; i++ {
-		v0, p0 := liIonCurve[i].mv, liIonCurve[i].pct
-		v1, p1 := liIonCurve[i+1].mv, liIonCurve[i+1].pct
-		if mv >= v0 && mv <= v1 {
-			dx := v1 - v0
-			if dx <= 0 {
-				return p0
-			}
-			return p0 + (p1-p0)*int(mv-v0)/int(dx)
-		}
-	}
-	return 0
+	return curvePct(liIonCurve, mv)
 }
 
 func voltageToPctLiIon(mv int32, emptyMV, fullMV int32) int {
@@ -96,39 +99,71 @@ func voltageToPctLiIon(mv int32, emptyMV, fullMV int32) int {
 
 type BatteryReading struct {
 	VoltageMV int32  // сглаженное напряжение, мВ
-	RawADC    uint32 // сырое значение АЦП (0..65535)
-	Pct       int
-	Charging  bool
-	TimeLeft  string
+	OpenMV    int32  // сглаженное напряжение холостого хода (с поправкой на нагрузку), мВ
+	LoadMA    int32  // оценка тока разряда, мА
+	RawADC    uint32 // значение АЦП после фильтра (0..65520)
+	Pct       int    // заряд, % — медленный фильтр, при разряде не растёт
+	State     ChargeState
+	Charging  bool // State == ChargeCharging
+
+	// Оценки времени, 0 — нет оценки (не тот режим или мало данных).
+	// TimeConfidence — доверие к ним от 0 до 1: растёт, пока копится история.
+	// Для экрана — FormatBatteryTime.
+	TimeToEmpty    time.Duration
+	TimeToFull     time.Duration
+	TimeConfidence float32
 }
 
 type Battery struct {
 	adc         machine.ADC
 	cfg         BatteryConfig
-	lastVMV     int32
-	lastAt      time.Time
 	smoothedVMV float64
-	charging    bool // зарядка только по сырому АЦП: raw >= rawAdcChgEnter
+	smoothedOCV float64
+	chg         chargeDetector
+	load        batteryLoad
+	soc         float64 // отфильтрованный процент
+	socAt       time.Time
+	est         timeEstimator
+	cal         BatteryCalibration
+	refs        []adcRef
+	log         calLog
+	lastRawMV   int32 // последнее напряжение без поправки АЦП
+	alerts      batteryAlerts
 }
 
 func NewBattery(pin machine.Pin, cfg BatteryConfig) *Battery {
 	return &Battery{
-		adc:     machine.ADC{Pin: pin},
-		cfg:     cfg,
-		lastVMV: -1,
+		adc:    machine.ADC{Pin: pin},
+		cfg:    cfg,
+		chg:    chargeDetector{vbusPin: machine.NoPin, statPin: machine.NoPin},
+		alerts: batteryAlerts{th: DefaultBatteryThresholds()},
 	}
 }
 
-func (b *Battery) Configure() {
-	machine.InitADC()
-	b.adc.Configure(machine.ADCConfig{})
+// Configure настраивает АЦП и, если задан BatteryConfig.Store, загружает
+// калибровку. Ошибка чтения калибровки не мешает работе: остаются настройки
+// конфигурации.
+func (b *Battery) Configure() error {
+	b.configureADC()
+	if b.cfg.Store != nil {
+		return b.LoadCalibration(b.cfg.Store)
+	}
+	return nil
 }
 
 func (b *Battery) Read() BatteryReading {
 	var r BatteryReading
-	raw := b.adc.Get()
-	r.RawADC = uint32(raw)
-	rawVMV := int32(uint32(raw)*uint32(adcRefMV)*uint32(b.cfg.Divider)) / int32(adcMax)
+	r.RawADC = b.sampleADC()
+	now := time.Now()
+	rawVMV := b.adcToMV(r.RawADC)
+	b.lastRawMV = rawVMV
+	rawVMV = b.cal.correct(rawVMV)
+
+	// Состояние — по тренду напряжения до ограничения диапазона, иначе не
+	// видно ни отсутствия батареи, ни роста выше ChargedMV.
+	r.State = b.chg.update(b.compensate(rawVMV), &b.cfg, now)
+	r.Charging = r.State == ChargeCharging
+
 	if rawVMV < b.cfg.EmptyMV {
 		rawVMV = b.cfg.EmptyMV
 	}
@@ -136,13 +171,6 @@ func (b *Battery) Read() BatteryReading {
 		rawVMV = b.cfg.ChargedMV
 	}
 
-	if r.RawADC >= rawAdcChgEnter {
-		b.charging = true
-	} else if r.RawADC < rawAdcChgExit {
-		b.charging = false
-	}
-	r.Charging = b.charging
-
 	alpha := smoothAlpha
 	if r.Charging {
 		alpha = smoothAlphaChg
@@ -155,18 +183,45 @@ func (b *Battery) Read() BatteryReading {
 	}
 	r.VoltageMV = int32(b.smoothedVMV)
 
-	if r.Charging {
+	// Поправка на нагрузку — по каждому отсчёту до сглаживания, чтобы скачок
+	// тока сразу компенсировался. При зарядке ток неизвестен — без поправки.
+	ocv := rawVMV
+	if r.State == ChargeDischarging {
+		r.LoadMA = b.LoadMA()
+		ocv = b.compensate(rawVMV)
+		if ocv > b.cfg.ChargedMV {
+			ocv = b.cfg.ChargedMV
+		}
+	}
+	if b.smoothedOCV == 0 {
+		b.smoothedOCV = float64(ocv)
+	} else {
+		b.smoothedOCV = alpha*float64(ocv) + (1-alpha)*b.smoothedOCV
+	}
+	r.OpenMV = int32(b.smoothedOCV)
+	b.logCalibration(&r, now)
+
+	switch {
+	case r.State == ChargeNoBattery:
+		r.Pct = 0
+	case r.State == ChargeFull:
+		r.Pct = 100
+	case r.Charging:
 		if rawVMV >= b.cfg.ChargedMV {
 			r.Pct = 100
 		} else {
 			r.Pct = int((rawVMV - b.cfg.FullMV) * 100 / (b.cfg.ChargedMV - b.cfg.FullMV))
 		}
-	} else {
+	default:
 		switch b.cfg.SoC {
 		case SoCLiIon:
-			r.Pct = voltageToPctLiIon(r.VoltageMV, b.cfg.EmptyMV, b.cfg.FullMV)
+			if len(b.cal.Curve) >= 2 {
+				r.Pct = curvePct(b.cal.Curve, r.OpenMV)
+				break
+			}
+			r.Pct = voltageToPctLiIon(r.OpenMV, b.cfg.EmptyMV, b.cfg.FullMV)
 		default:
-			r.Pct = int((r.VoltageMV - b.cfg.EmptyMV) * 100 / (b.cfg.FullMV - b.cfg.EmptyMV))
+			r.Pct = int((r.OpenMV - b.cfg.EmptyMV) * 100 / (b.cfg.FullMV - b.cfg.EmptyMV))
 		}
 	}
 	if r.Pct < 0 {
@@ -175,43 +230,13 @@ func (b *Battery) Read() BatteryReading {
 	if r.Pct > 100 {
 		r.Pct = 100
 	}
-
-	if r.Charging {
-		now := time.Now()
-		if b.lastVMV >= 0 && rawVMV > b.lastVMV {
-			elapsed := now.Sub(b.lastAt).Seconds()
-			if elapsed >= 4 {
-				rateMVps := float64(rawVMV-b.lastVMV) / elapsed
-				if rateMVps > 2 {
-					remaining := int32(b.cfg.ChargedMV - rawVMV)
-					if remaining > 0 {
-						secLeft := float64(remaining) / rateMVps
-						r.TimeLeft = formatBatteryTimeLeft(int64(secLeft))
-					}
-				}
-			}
-		}
-		b.lastVMV = rawVMV
-		b.lastAt = now
+	if r.State == ChargeNoBattery {
+		b.socAt = time.Time{} // с новой батареей фильтр начнёт заново
 	} else {
-		b.lastVMV = -1
+		r.Pct = b.filterSoC(r.Pct, r.State != ChargeDischarging, now)
+		b.updateTimes(&r, now)
 	}
+	b.checkAlerts(&r, now)
 
 	return r
 }
-
-func formatBatteryTimeLeft(sec int64) string {
-	if sec < 60 {
-		return "<1 min"
-	}
-	min := sec / 60
-	if min < 60 {
-		return strconv.FormatInt(min, 10) + " min"
-	}
-	h := min / 60
-	m := min % 60
-	if m == 0 {
-		return strconv.FormatInt(h, 10) + " h"
-	}
-	return strconv.FormatInt(h, 10) + " h " + strconv.FormatInt(m, 10) + " min"
-}
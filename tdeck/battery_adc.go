@@ -0,0 +1,134 @@
+package tdeck
+
+import "machine"
+
+// АЦП ESP32-S3 — 12 бит; TinyGo растягивает отсчёт до 16 бит сдвигом,
+// поэтому сырые значения доходят только до 4095<<4 = 65520.
+const (
+	adcBits  = 12
+	adcShift = 16 - adcBits
+	adcCodes = 1<<adcBits - 1
+
+	// Прежняя шкала драйвера: 3300 мВ на 65535. Пороги DefaultBatteryConfig и
+	// определения зарядки подобраны под неё.
+	legacyADCMax   = 1<<16 - 1
+	legacyADCRefMV = 3300
+
+	defaultADCSamples = 16
+	maxADCSamples     = 32
+)
+
+// ADCAttenuation — ослабление входа АЦП, задаёт диапазон измерения.
+// Драйвер ослабление не настраивает: его выбирает порт TinyGo в
+// machine.ADC.Configure, а значение в BatteryConfig должно с ним совпадать,
+// иначе напряжение будет в другом масштабе. Пока шкала порта не измерена на
+// плате, по умолчанию — AttenDefault с прежней шкалой драйвера.
+type ADCAttenuation uint8
+
+const (
+	AttenDefault ADCAttenuation = iota // как настроил порт; шкала 3300 мВ на 65535
+	Atten0dB                           // 0–950 мВ
+	Atten2_5dB                         // 0–1250 мВ
+	Atten6dB                           // 0–1750 мВ
+	Atten12dB                          // 0–3100 мВ
+)
+
+// FullScaleMV возвращает номинальное напряжение полной шкалы, мВ, по
+// документации ESP32-S3. Реальная шкала отличается от чипа к чипу на
+// несколько процентов; точнее — AddADCReference.
+func (a ADCAttenuation) FullScaleMV() int32 {
+	switch a {
+	case AttenDefault:
+		return legacyADCRefMV
+	case Atten0dB:
+		return 950
+	case Atten2_5dB:
+		return 1250
+	case Atten6dB:
+		return 1750
+	}
+	return 3100
+}
+
+// ADCFilter — как объединять отсчёты одного измерения.
+type ADCFilter uint8
+
+const (
+	ADCTrimmedMean ADCFilter = iota // среднее без крайних четвертей: и шум, и выбросы
+	ADCMedian                       // медиана: устойчива к выбросам от радио
+	ADCMean                         // среднее: меньше всего шума, если нет выбросов
+)
+
+func (b *Battery) configureADC() {
+	machine.InitADC()
+	b.adc.Configure(machine.ADCConfig{})
+}
+
+// adcConversion описывает перевод кода АЦП в мВ. Сохранённые поправки и
+// журнал разряда верны только для того перевода, при котором их сняли.
+func (b *Battery) adcConversion() uint8 {
+	return uint8(b.cfg.ADCAttenuation)
+}
+
+// sampleADC делает cfg.ADCSamples отсчётов и объединяет их фильтром.
+// Возвращает 16-битное значение, как machine.ADC.Get.
+func (b *Battery) sampleADC() uint32 {
+	n := int(b.cfg.ADCSamples)
+	if n <= 0 {
+		n = 1
+	}
+	if n > maxADCSamples {
+		n = maxADCSamples
+	}
+	var buf [maxADCSamples]uint16
+	s := buf[:n]
+	for i := range s {
+		s[i] = b.adc.Get()
+	}
+	if n == 1 {
+		return uint32(s[0])
+	}
+
+	var lo, hi int
+	switch b.cfg.ADCFilter {
+	case ADCMean:
+		lo, hi = 0, n
+	case ADCMedian:
+		sortSamples(s)
+		if n%2 == 1 {
+			return uint32(s[n/2])
+		}
+		lo, hi = n/2-1, n/2+1
+	default:
+		sortSamples(s)
+		lo, hi = n/4, n-n/4
+	}
+	var sum uint32
+	for _, v := range s[lo:hi] {
+		sum += uint32(v)
+	}
+	return sum / uint32(hi-lo)
+}
+
+// sortSamples — сортировка вставками: отсчётов мало, а sort тянет лишний код.
+func sortSamples(s []uint16) {
+	for i := 1; i < len(s); i++ {
+		v := s[i]
+		j := i
+		for ; j > 0 && s[j-1] > v; j-- {
+			s[j] = s[j-1]
+		}
+		s[j] = v
+	}
+}
+
+// adcToMV переводит 16-битное значение АЦП в напряжение батареи, мВ.
+// Дробная часть кода от усреднения сохраняется.
+func (b *Battery) adcToMV(raw uint32) int32 {
+	a := b.cfg.ADCAttenuation
+	den := int64(adcCodes) << adcShift
+	if a == AttenDefault {
+		den = legacyADCMax
+	}
+	return int32(int64(raw) * int64(a.FullScaleMV()) * int64(b.cfg.Divider) / den)
+}
@@ -0,0 +1,41 @@
+package tdeck
+
+import "testing"
+
+func TestADCToMV(t *testing.T) {
+	tests := []struct {
+		name  string
+		atten ADCAttenuation
+		raw   uint32
+		want  int32
+	}{
+		{"zero", Atten12dB, 0, 0},
+		{"12dB full scale", Atten12dB, 4095 << adcShift, 2 * 3100},
+		{"12dB half scale", Atten12dB, 32760, 2 * 1550},
+		{"6dB full scale", Atten6dB, 4095 << adcShift, 2 * 1750},
+		{"sub-code resolution", Atten12dB, 32760 + 16, 3101},
+		{"default full scale", AttenDefault, 65535, 2 * 3300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultBatteryConfig()
+			cfg.ADCAttenuation = tt.atten
+			b := NewBattery(0, cfg)
+			if got := b.adcToMV(tt.raw); got != tt.want {
+				t.Errorf("adcToMV(%d) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestADCToMVDefaultScale checks that the default config keeps the scale
+// the thresholds of DefaultBatteryConfig were chosen for.
+func TestADCToMVDefaultScale(t *testing.T) {
+	b := NewBattery(0, DefaultBatteryConfig())
+	for _, raw := range []uint32{0, 30000, 40000, 55000, 62000, 65520} {
+		want := int32(raw * 3300 * 2 / 65535)
+		if got := b.adcToMV(raw); got != want {
+			t.Errorf("adcToMV(%d) = %d, want %d", raw, got, want)
+		}
+	}
+}
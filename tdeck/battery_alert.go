@@ -0,0 +1,210 @@
+package tdeck
+
+import "time"
+
+// BatteryAlert — событие уровня заряда.
+type BatteryAlert uint8
+
+const (
+	AlertLow       BatteryAlert = iota + 1 // заряд опустился до LowPct (или поднялся из критического)
+	AlertCritical                          // заряд до CriticalPct или напряжение до CriticalMV
+	AlertRecovered                         // заряд вернулся выше порогов или началась зарядка
+)
+
+func (a BatteryAlert) String() string {
+	switch a {
+	case AlertLow:
+		return "low"
+	case AlertCritical:
+		return "critical"
+	case AlertRecovered:
+		return "recovered"
+	}
+	return "none"
+}
+
+// BatteryAlertEvent — событие с показаниями, при которых оно случилось.
+type BatteryAlertEvent struct {
+	Alert     BatteryAlert
+	Pct       int
+	VoltageMV int32
+	State     ChargeState
+	Time      time.Time
+}
+
+// BatteryThresholds — пороги событий. Уровень возвращается назад, только
+// когда заряд поднимется на HysteresisPct (и напряжение на HysteresisMV)
+// выше порога, чтобы события не дребезжали у границы. Зарядка сбрасывает
+// уровень, только если продержалась RecoverAfter: состояние определяется по
+// тренду напряжения и может ненадолго смениться, например когда Dim снизил
+// нагрузку.
+type BatteryThresholds struct {
+	LowPct        int
+	CriticalPct   int
+	CriticalMV    int32 // по напряжению под нагрузкой: от него зависит просадка до brown-out
+	HysteresisPct int
+	HysteresisMV  int32
+	RecoverAfter  time.Duration
+}
+
+func DefaultBatteryThresholds() BatteryThresholds {
+	return BatteryThresholds{
+		LowPct:        20,
+		CriticalPct:   5,
+		CriticalMV:    3300,
+		HysteresisPct: 3,
+		HysteresisMV:  50,
+		RecoverAfter:  30 * time.Second,
+	}
+}
+
+// ShutdownPolicy — что делать при критическом заряде. Сначала вызываются
+// Dim и StopAudio, а если через Grace заряд всё ещё критический — Sleep.
+// Sleep обычно гасит периферию и уводит плату в глубокий сон; в TinyGo нет
+// общего API сна, поэтому это функция приложения. Grace оставляет время
+// закрыть файлы на SD-карте по событию AlertCritical. Любое поле может быть nil.
+type ShutdownPolicy struct {
+	Dim       func()
+	StopAudio func()
+	Sleep     func()
+	Grace     time.Duration
+}
+
+const defaultShutdownGrace = 10 * time.Second
+
+type batteryLevel uint8
+
+const (
+	levelOK batteryLevel = iota
+	levelLow
+	levelCritical
+)
+
+type batteryAlerts struct {
+	th      BatteryThresholds
+	level   batteryLevel
+	handler func(BatteryAlertEvent)
+	ch      chan<- BatteryAlertEvent
+
+	policy     *ShutdownPolicy
+	criticalAt time.Time // начало отсчёта Grace
+	leftAt     time.Time // когда уровень последний раз вышел из критического
+	chargeAt   time.Time // с какого момента батарея не разряжается
+	slept      bool
+}
+
+// SetAlertThresholds задаёт пороги событий.
+func (b *Battery) SetAlertThresholds(th BatteryThresholds) {
+	b.alerts.th = th
+}
+
+// SetAlertHandler задаёт функцию, которую Read вызывает для каждого события.
+func (b *Battery) SetAlertHandler(h func(BatteryAlertEvent)) {
+	b.alerts.handler = h
+}
+
+// NotifyAlerts отправляет события в ch. Read не ждёт получателя: если канал
+// полон, событие теряется, поэтому канал стоит делать буферизованным.
+func (b *Battery) NotifyAlerts(ch chan<- BatteryAlertEvent) {
+	b.alerts.ch = ch
+}
+
+// SetShutdownPolicy включает политику отключения; nil выключает её.
+func (b *Battery) SetShutdownPolicy(p *ShutdownPolicy) {
+	b.alerts.policy = p
+}
+
+// checkAlerts сравнивает показания с порогами и выполняет политику.
+func (b *Battery) checkAlerts(r *BatteryReading, now time.Time) {
+	a := &b.alerts
+	th := &a.th
+	level := a.level
+	if r.State == ChargeDischarging {
+		a.chargeAt = time.Time{}
+	} else if a.chargeAt.IsZero() {
+		a.chargeAt = now
+	}
+	switch {
+	case r.State != ChargeDischarging:
+		if now.Sub(a.chargeAt) >= th.RecoverAfter {
+			level = levelOK
+		}
+	case r.Pct <= th.CriticalPct || r.VoltageMV <= th.CriticalMV:
+		level = levelCritical
+	case level == levelCritical && (r.Pct < th.CriticalPct+th.HysteresisPct || r.VoltageMV < th.CriticalMV+th.HysteresisMV):
+		// Остаётся критическим до выхода из петли гистерезиса.
+	case r.Pct <= th.LowPct:
+		level = levelLow
+	case level != levelOK && r.Pct < th.LowPct+th.HysteresisPct:
+		level = levelLow
+	default:
+		level = levelOK
+	}
+
+	if level != a.level {
+		if a.level == levelCritical {
+			a.leftAt = now
+		}
+		a.level = level
+		alert := AlertRecovered
+		switch level {
+		case levelLow:
+			alert = AlertLow
+		case levelCritical:
+			alert = AlertCritical
+			// Уровень, вернувшийся в критический за Grace, продолжает
+			// прежний отсчёт: дребезг не должен откладывать Sleep.
+			if a.leftAt.IsZero() || now.Sub(a.leftAt) >= a.grace() {
+				a.criticalAt = now
+				a.slept = false
+			}
+			b.prepareShutdown()
+		}
+		b.emitAlert(BatteryAlertEvent{Alert: alert, Pct: r.Pct, VoltageMV: r.VoltageMV, State: r.State, Time: now})
+	}
+
+	p := a.policy
+	if level != levelCritical || p == nil || p.Sleep == nil || a.slept {
+		return
+	}
+	if now.Sub(a.criticalAt) >= a.grace() {
+		// Если Sleep вернулся (плата проснулась на том же заряде), повтор —
+		// только после нового входа в критический уровень.
+		a.slept = true
+		p.Sleep()
+	}
+}
+
+func (a *batteryAlerts) grace() time.Duration {
+	if a.policy == nil || a.policy.Grace <= 0 {
+		return defaultShutdownGrace
+	}
+	return a.policy.Grace
+}
+
+func (b *Battery) prepareShutdown() {
+	p := b.alerts.policy
+	if p == nil {
+		return
+	}
+	if p.Dim != nil {
+		p.Dim()
+	}
+	if p.StopAudio != nil {
+		p.StopAudio()
+		b.SetAudioLoad(false)
+	}
+}
+
+func (b *Battery) emitAlert(e BatteryAlertEvent) {
+	a := &b.alerts
+	if a.handler != nil {
+		a.handler(e)
+	}
+	if a.ch != nil {
+		select {
+		case a.ch <- e:
+		default:
+		}
+	}
+}
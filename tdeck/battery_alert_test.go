@@ -0,0 +1,66 @@
+package tdeck
+
+import (
+	"testing"
+	"time"
+)
+
+type alertScript struct {
+	b      *Battery
+	now    time.Time
+	alerts []BatteryAlert
+	sleeps int
+}
+
+func newAlertScript() *alertScript {
+	s := &alertScript{b: NewBattery(0, DefaultBatteryConfig()), now: time.Unix(0, 0)}
+	s.b.SetAlertHandler(func(e BatteryAlertEvent) { s.alerts = append(s.alerts, e.Alert) })
+	s.b.SetShutdownPolicy(&ShutdownPolicy{Sleep: func() { s.sleeps++ }})
+	return s
+}
+
+func (s *alertScript) read(state ChargeState, pct int, after time.Duration) {
+	s.now = s.now.Add(after)
+	s.b.checkAlerts(&BatteryReading{State: state, Pct: pct, VoltageMV: 3200}, s.now)
+}
+
+func TestAlertsShortChargeKeepsCritical(t *testing.T) {
+	s := newAlertScript()
+	s.read(ChargeDischarging, 3, 0)
+	// The voltage rises once Dim lowers the load and looks like charging.
+	s.read(ChargeCharging, 3, 2*time.Second)
+	s.read(ChargeDischarging, 3, 2*time.Second)
+	s.read(ChargeDischarging, 3, 7*time.Second)
+	if len(s.alerts) != 1 || s.alerts[0] != AlertCritical {
+		t.Fatalf("alerts = %v, want [critical]", s.alerts)
+	}
+	if s.sleeps != 1 {
+		t.Fatalf("Sleep called %d times, want 1", s.sleeps)
+	}
+}
+
+func TestAlertsFlapKeepsGrace(t *testing.T) {
+	s := newAlertScript()
+	s.b.alerts.th.RecoverAfter = 0
+	s.read(ChargeDischarging, 3, 0)
+	for i := 0; i < 4; i++ {
+		s.read(ChargeCharging, 3, time.Second)
+		s.read(ChargeDischarging, 3, 2*time.Second)
+	}
+	if s.sleeps != 1 {
+		t.Fatalf("Sleep called %d times after flapping for %v, want 1", s.sleeps, s.now.Sub(time.Unix(0, 0)))
+	}
+}
+
+func TestAlertsChargeRecovers(t *testing.T) {
+	s := newAlertScript()
+	s.read(ChargeDischarging, 3, 0)
+	s.read(ChargeCharging, 3, time.Second)
+	s.read(ChargeCharging, 3, DefaultBatteryThresholds().RecoverAfter)
+	if len(s.alerts) != 2 || s.alerts[1] != AlertRecovered {
+		t.Fatalf("alerts = %v, want [critical recovered]", s.alerts)
+	}
+	if s.sleeps != 0 {
+		t.Fatalf("Sleep called %d times, want 0", s.sleeps)
+	}
+}
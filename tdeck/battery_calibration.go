@@ -0,0 +1,381 @@
+package tdeck
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	drivers "github.com/dimajolkin/tinygo-lilygo-drivers"
+)
+
+// Ключи в Store.
+const (
+	BatteryCalibrationKey = "battery.cal"
+	batteryLogKey         = "battery.log"
+)
+
+const (
+	// Версия 2: в записи хранится перевод код АЦП → мВ (adcConversion).
+	// Версия 1 его не хранит: она снята при прежней шкале, AttenDefault.
+	batteryCalVersion = 2
+	batteryLogVersion = 2
+	calHeaderSize     = 15
+	logHeaderSize     = 8
+
+	calLogSize     = 128 // отсчётов в журнале разряда
+	calLogStepMAh  = 2   // начальный шаг журнала по заряду, мА·ч
+	calLogSaveEach = 16  // сохранять журнал каждые N отсчётов
+	calMinMAh      = 100 // меньше — разряд слишком короткий для кривой
+	maxCurvePoints = 16
+)
+
+var (
+	errCalibrationShort = errors.New("tdeck: battery calibration log too short")
+	errCalibrationData  = errors.New("tdeck: invalid battery calibration data")
+	errNoADCReference   = errors.New("tdeck: no battery reading for ADC reference")
+	errCalibrationStale = errors.New("tdeck: battery calibration made with another ADC conversion, recalibrate")
+)
+
+// CurvePoint — точка кривой напряжение → заряд.
+type CurvePoint struct {
+	MV  int32
+	Pct int
+}
+
+// calibrationPcts — уровни, в которых строится выученная кривая.
+var calibrationPcts = [...]int{0, 5, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+// BatteryCalibration — поправки конкретной платы и батареи.
+//
+// Gain и OffsetMV исправляют АЦП: mV = измерено·Gain + OffsetMV. Curve
+// заменяет типовую кривую Li-ion (SoCLiIon), CapacityMAh — ёмкость из
+// BatteryConfig; нулевые значения оставляют настройки конфигурации.
+type BatteryCalibration struct {
+	Gain        float32
+	OffsetMV    int32
+	Curve       []CurvePoint
+	CapacityMAh int32
+
+	conv uint8 // adcConversion, при котором сняты поправки
+}
+
+func (c *BatteryCalibration) correct(mv int32) int32 {
+	if c.Gain == 0 {
+		return mv + c.OffsetMV
+	}
+	return int32(float32(mv)*c.Gain+0.5) + c.OffsetMV
+}
+
+// MarshalBinary кодирует калибровку для Store.
+func (c BatteryCalibration) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, calHeaderSize+3*len(c.Curve))
+	b = append(b, batteryCalVersion, c.conv)
+	b = binary.LittleEndian.AppendUint32(b, math.Float32bits(c.Gain))
+	b = binary.LittleEndian.AppendUint32(b, uint32(c.OffsetMV))
+	b = binary.LittleEndian.AppendUint32(b, uint32(c.CapacityMAh))
+	b = append(b, uint8(len(c.Curve)))
+	for _, p := range c.Curve {
+		b = binary.LittleEndian.AppendUint16(b, uint16(p.MV))
+		b = append(b, uint8(p.Pct))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary декодирует калибровку, записанную MarshalBinary.
+func (c *BatteryCalibration) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] == 1 {
+		b = append([]byte{batteryCalVersion, uint8(AttenDefault)}, b[1:]...)
+	}
+	if len(b) < calHeaderSize || b[0] != batteryCalVersion {
+		return errCalibrationData
+	}
+	n := int(b[calHeaderSize-1])
+	if n > maxCurvePoints || len(b) != calHeaderSize+3*n {
+		return errCalibrationData
+	}
+	c.conv = b[1]
+	c.Gain = math.Float32frombits(binary.LittleEndian.Uint32(b[2:]))
+	c.OffsetMV = int32(binary.LittleEndian.Uint32(b[6:]))
+	c.CapacityMAh = int32(binary.LittleEndian.Uint32(b[10:]))
+	c.Curve = make([]CurvePoint, n)
+	for i := range c.Curve {
+		p := b[calHeaderSize+3*i:]
+		c.Curve[i] = CurvePoint{MV: int32(binary.LittleEndian.Uint16(p)), Pct: int(p[2])}
+	}
+	return nil
+}
+
+// SetCalibration применяет калибровку. Она считается снятой при текущем
+// переводе кода АЦП в мВ.
+func (b *Battery) SetCalibration(c BatteryCalibration) {
+	c.conv = b.adcConversion()
+	b.cal = c
+	if c.CapacityMAh > 0 {
+		b.cfg.CapacityMAh = c.CapacityMAh
+	}
+}
+
+// Calibration возвращает текущую калибровку.
+func (b *Battery) Calibration() BatteryCalibration {
+	return b.cal
+}
+
+// SaveCalibration сохраняет калибровку в s под BatteryCalibrationKey.
+func (b *Battery) SaveCalibration(s drivers.Store) error {
+	b.cal.conv = b.adcConversion()
+	data, _ := b.cal.MarshalBinary()
+	return s.Save(BatteryCalibrationKey, data)
+}
+
+// LoadCalibration загружает и применяет калибровку из s, а также журнал
+// незаконченного разряда, если он есть (см. FinishCalibration). Вызывать
+// после Configure: калибровка и журнал, снятые при другом ослаблении АЦП,
+// отбрасываются с ошибкой.
+func (b *Battery) LoadCalibration(s drivers.Store) error {
+	var buf [calHeaderSize + 3*maxCurvePoints]byte
+	n, err := s.Load(BatteryCalibrationKey, buf[:])
+	if err == nil {
+		var c BatteryCalibration
+		err = c.UnmarshalBinary(buf[:n])
+		if err == nil && c.conv != b.adcConversion() {
+			err = errCalibrationStale
+		}
+		if err == nil {
+			b.SetCalibration(c)
+		}
+	}
+	if err == drivers.ErrNotStored {
+		err = nil
+	}
+	// Журнал проверяется отдельно и загружается, даже если калибровка
+	// отброшена.
+	if lerr := b.loadLog(s); lerr != nil {
+		return lerr
+	}
+	return err
+}
+
+// AddADCReference сопоставляет последнее измерение с напряжением батареи,
+// измеренным мультиметром, и пересчитывает Gain и OffsetMV: по одной точке —
+// только усиление, по двум и более — усиление и смещение. Точки лучше брать
+// при разном заряде; они копятся до перезагрузки.
+func (b *Battery) AddADCReference(actualMV int32) error {
+	if b.lastRawMV <= 0 {
+		return errNoADCReference
+	}
+	b.refs = append(b.refs, adcRef{measured: b.lastRawMV, actual: actualMV})
+	b.fitADC()
+	return nil
+}
+
+type adcRef struct {
+	measured, actual int32
+}
+
+func (b *Battery) fitADC() {
+	n := float64(len(b.refs))
+	var sx, sy, sxx, sxy float64
+	for _, r := range b.refs {
+		x, y := float64(r.measured), float64(r.actual)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	d := n*sxx - sx*sx
+	if len(b.refs) < 2 || math.Abs(d) < 1 {
+		b.cal.Gain = float32(sy / sx)
+		b.cal.OffsetMV = 0
+		return
+	}
+	gain := (n*sxy - sx*sy) / d
+	b.cal.Gain = float32(gain)
+	b.cal.OffsetMV = int32((sy - gain*sx) / n)
+}
+
+// calLog — журнал разряда для калибровки: напряжение холостого хода через
+// равные порции отданного заряда. Когда журнал заполнен, каждая вторая точка
+// отбрасывается, а шаг удваивается.
+type calLog struct {
+	running bool
+	step    float32 // мА·ч между отсчётами
+	charge  float64 // отдано с последнего отсчёта, мА·ч
+	at      time.Time
+	n       int
+	saved   int
+	mv      [calLogSize]uint16
+}
+
+// StartCalibration начинает журнал разряда. Запускать с полностью
+// заряженной батареей, отключив USB, и дать ей разрядиться до выключения
+// платы при постоянной нагрузке: заряд считается по LoadMA, поэтому форма
+// кривой верна при любой постоянной нагрузке, а ёмкость — настолько, насколько
+// точны BatteryConfig.Loads. Если задан BatteryConfig.Store, журнал
+// периодически сохраняется и переживает выключение; после перезагрузки
+// вызовите FinishCalibration. Выключение может прервать сохранение, поэтому
+// Store не должен терять прежние данные при прерванной записи (BlockStore
+// пишет в два слота по очереди).
+func (b *Battery) StartCalibration() {
+	b.log = calLog{running: true, step: calLogStepMAh}
+}
+
+// CalibrationRunning сообщает, идёт ли запись журнала разряда.
+func (b *Battery) CalibrationRunning() bool {
+	return b.log.running
+}
+
+// CalibrationPending сообщает, есть ли журнал для FinishCalibration.
+func (b *Battery) CalibrationPending() bool {
+	return b.log.n > 0
+}
+
+// FinishCalibration строит кривую по журналу разряда (последний отсчёт —
+// 0 %), применяет её вместе с выученной ёмкостью и, если задан
+// BatteryConfig.Store, сохраняет калибровку и очищает журнал.
+func (b *Battery) FinishCalibration() (BatteryCalibration, error) {
+	l := &b.log
+	l.running = false
+	total := float64(l.step) * float64(l.n-1)
+	if l.n < 2 || total < calMinMAh {
+		return b.cal, errCalibrationShort
+	}
+	c := b.cal
+	c.Curve = make([]CurvePoint, len(calibrationPcts))
+	for i, pct := range calibrationPcts {
+		// Отсчёт k отдан при заряде k·step, то есть при 100·(1-k·step/total) %.
+		pos := (1 - float64(pct)/100) * float64(l.n-1)
+		k := int(pos)
+		if k >= l.n-1 {
+			k = l.n - 2
+		}
+		f := pos - float64(k)
+		mv := float64(l.mv[k])*(1-f) + float64(l.mv[k+1])*f
+		c.Curve[i] = CurvePoint{MV: int32(mv + 0.5), Pct: pct}
+	}
+	// Шум журнала не должен давать немонотонную кривую.
+	for i := 1; i < len(c.Curve); i++ {
+		if c.Curve[i].MV <= c.Curve[i-1].MV {
+			c.Curve[i].MV = c.Curve[i-1].MV + 1
+		}
+	}
+	c.CapacityMAh = int32(total + 0.5)
+	b.SetCalibration(c)
+	*l = calLog{}
+	if s := b.cfg.Store; s != nil {
+		if err := b.SaveCalibration(s); err != nil {
+			return c, err
+		}
+		if err := s.Save(batteryLogKey, []byte{batteryLogVersion}); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// logCalibration копит отданный заряд и пишет отсчёты журнала.
+func (b *Battery) logCalibration(r *BatteryReading, now time.Time) {
+	l := &b.log
+	if !l.running {
+		return
+	}
+	if r.State != ChargeDischarging {
+		l.at = time.Time{}
+		return
+	}
+	if l.n == 0 {
+		l.mv[0] = uint16(r.OpenMV)
+		l.n = 1
+		l.at = now
+		return
+	}
+	if !l.at.IsZero() {
+		l.charge += float64(r.LoadMA) * now.Sub(l.at).Hours()
+	}
+	l.at = now
+	for l.charge >= float64(l.step) {
+		l.charge -= float64(l.step)
+		if l.n == calLogSize {
+			for i := 0; i < calLogSize/2; i++ {
+				l.mv[i] = l.mv[2*i]
+			}
+			l.n = calLogSize / 2
+			l.saved = 0
+			l.step *= 2
+			continue
+		}
+		l.mv[l.n] = uint16(r.OpenMV)
+		l.n++
+	}
+	if s := b.cfg.Store; s != nil && l.n-l.saved >= calLogSaveEach {
+		if b.saveLog(s) == nil {
+			l.saved = l.n
+		}
+	}
+}
+
+func (b *Battery) saveLog(s drivers.Store) error {
+	l := &b.log
+	data := make([]byte, 0, logHeaderSize+2*l.n)
+	data = append(data, batteryLogVersion, b.adcConversion())
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(l.step))
+	data = binary.LittleEndian.AppendUint16(data, uint16(l.n))
+	for _, mv := range l.mv[:l.n] {
+		data = binary.LittleEndian.AppendUint16(data, mv)
+	}
+	return s.Save(batteryLogKey, data)
+}
+
+func (b *Battery) loadLog(s drivers.Store) error {
+	var buf [logHeaderSize + 2*calLogSize]byte
+	n, err := s.Load(batteryLogKey, buf[:])
+	if err == drivers.ErrNotStored {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data := buf[:n]
+	if len(data) > 1 && data[0] == 1 {
+		data = append([]byte{batteryLogVersion, uint8(AttenDefault)}, data[1:]...)
+	}
+	if len(data) < logHeaderSize {
+		return nil // пустой или очищенный журнал
+	}
+	if data[0] != batteryLogVersion || data[1] != b.adcConversion() {
+		return errCalibrationStale
+	}
+	cnt := int(binary.LittleEndian.Uint16(data[6:]))
+	if cnt > calLogSize || len(data) != logHeaderSize+2*cnt {
+		return errCalibrationData
+	}
+	l := &b.log
+	*l = calLog{step: math.Float32frombits(binary.LittleEndian.Uint32(data[2:])), n: cnt, saved: cnt}
+	for i := range l.mv[:cnt] {
+		l.mv[i] = binary.LittleEndian.Uint16(data[logHeaderSize+2*i:])
+	}
+	return nil
+}
+
+// curvePct переводит напряжение в процент по кривой, упорядоченной по
+// возрастанию напряжения.
+func curvePct(curve []CurvePoint, mv int32) int {
+	if mv <= curve[0].MV {
+		return curve[0].Pct
+	}
+	last := curve[len(curve)-1]
+	if mv >= last.MV {
+		return last.Pct
+	}
+	for i := 0; i < len(curve)-1; i++ {
+		v0, p0 := curve[i].MV, curve[i].Pct
+		v1, p1 := curve[i+1].MV, curve[i+1].Pct
+		if mv <= v1 {
+			if v1 <= v0 {
+				return p0
+			}
+			return p0 + (p1-p0)*int(mv-v0)/int(v1-v0)
+		}
+	}
+	return last.Pct
+}
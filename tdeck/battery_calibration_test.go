@@ -0,0 +1,63 @@
+package tdeck
+
+import (
+	"testing"
+
+	drivers "github.com/dimajolkin/tinygo-lilygo-drivers"
+)
+
+type memStore map[string][]byte
+
+func (m memStore) Load(key string, buf []byte) (int, error) {
+	d, ok := m[key]
+	if !ok {
+		return 0, drivers.ErrNotStored
+	}
+	return copy(buf, d), nil
+}
+
+func (m memStore) Save(key string, data []byte) error {
+	m[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func TestCalibrationDroppedForOtherConversion(t *testing.T) {
+	store := memStore{}
+	b := NewBattery(0, DefaultBatteryConfig())
+	b.SetCalibration(BatteryCalibration{Gain: 1.05, OffsetMV: -20, CapacityMAh: 1500,
+		Curve: []CurvePoint{{3300, 0}, {3700, 50}, {4150, 100}}})
+	if err := b.SaveCalibration(store); err != nil {
+		t.Fatal(err)
+	}
+
+	same := NewBattery(0, DefaultBatteryConfig())
+	if err := same.LoadCalibration(store); err != nil {
+		t.Fatalf("same conversion: %v", err)
+	}
+	if c := same.Calibration(); c.Gain != 1.05 || c.OffsetMV != -20 || len(c.Curve) != 3 {
+		t.Fatalf("loaded %+v", c)
+	}
+
+	cfg := DefaultBatteryConfig()
+	cfg.ADCAttenuation = Atten6dB
+	other := NewBattery(0, cfg)
+	if err := other.LoadCalibration(store); err != errCalibrationStale {
+		t.Fatalf("other attenuation: err = %v, want errCalibrationStale", err)
+	}
+	if c := other.Calibration(); c.Gain != 0 || c.Curve != nil {
+		t.Fatalf("stale calibration applied: %+v", c)
+	}
+
+	// Version 1 records were made with the 3300 mV scale of AttenDefault.
+	store[BatteryCalibrationKey] = []byte{1, 0, 0, 0x80, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	v1 := NewBattery(0, DefaultBatteryConfig())
+	if err := v1.LoadCalibration(store); err != nil {
+		t.Fatalf("version 1: %v", err)
+	}
+	if c := v1.Calibration(); c.Gain != 1 {
+		t.Fatalf("version 1: loaded %+v", c)
+	}
+	if err := other.LoadCalibration(store); err != errCalibrationStale {
+		t.Fatalf("version 1, other attenuation: err = %v, want errCalibrationStale", err)
+	}
+}
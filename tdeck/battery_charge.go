@@ -0,0 +1,203 @@
+package tdeck
+
+import (
+	"machine"
+	"time"
+)
+
+// ChargeState — состояние питания от батареи.
+type ChargeState uint8
+
+const (
+	ChargeDischarging ChargeState = iota // питание от батареи
+	ChargeCharging                       // внешнее питание, батарея заряжается
+	ChargeFull                           // внешнее питание, заряд окончен
+	ChargeNoBattery                      // внешнее питание, батареи нет
+)
+
+func (s ChargeState) String() string {
+	switch s {
+	case ChargeCharging:
+		return "charging"
+	case ChargeFull:
+		return "full"
+	case ChargeNoBattery:
+		return "no battery"
+	}
+	return "discharging"
+}
+
+const (
+	trendSamples = 16
+	trendStep    = 4 * time.Second // окно тренда — trendSamples·trendStep ≈ 1 мин
+	trendMin     = 4               // отсчётов, чтобы считать наклон
+
+	chargeSlope    = 3.0  // мВ/мин: устойчивый рост — зарядка
+	dischargeSlope = -1.0 // мВ/мин: устойчивое падение — разряд
+	flatSlope      = 0.5  // мВ/мин: почти ровно — заряд окончен
+	chargeStepMV   = 80   // скачок при подключении/отключении USB (ток заряда × R)
+	fullMarginMV   = 30   // «полный» — не ниже ChargedMV-fullMarginMV
+	noBatteryMV    = 2500 // ниже — батареи нет, плата живёт от USB
+	noBatteryRipMV = 300  // размах в окне больше — зарядник без батареи
+)
+
+type trendSample struct {
+	at time.Time
+	mv int32
+}
+
+// chargeDetector определяет состояние по тренду напряжения за окно и, если
+// заданы, по пинам VBUS и статуса зарядника.
+type chargeDetector struct {
+	samples [trendSamples]trendSample
+	n       int
+	next    int
+	state   ChargeState
+
+	vbusPin      machine.Pin
+	statPin      machine.Pin
+	statActiveLo bool
+}
+
+// SetChargeStatusPins задаёт пины вариантов платы с сигналами зарядника:
+// vbus — высокий уровень при наличии USB-питания, stat — выход статуса
+// зарядника (активный низкий у TP4056/TP4065, если activeLow). Любой из них
+// может быть machine.NoPin; без пинов состояние определяется по тренду
+// напряжения.
+func (b *Battery) SetChargeStatusPins(vbus, stat machine.Pin, activeLow bool) {
+	c := &b.chg
+	c.vbusPin, c.statPin, c.statActiveLo = vbus, stat, activeLow
+	for _, p := range []machine.Pin{vbus, stat} {
+		if p != machine.NoPin {
+			p.Configure(machine.PinConfig{Mode: machine.PinInput})
+		}
+	}
+}
+
+// update добавляет отсчёт (напряжение с поправкой на нагрузку приложения,
+// мВ, без ограничения диапазона) и возвращает новое состояние.
+func (c *chargeDetector) update(mv int32, cfg *BatteryConfig, now time.Time) ChargeState {
+	c.add(mv, now)
+
+	vbus, vbusKnown := c.readPin(c.vbusPin, false)
+	stat, statKnown := c.readPin(c.statPin, c.statActiveLo)
+	if vbusKnown && !vbus {
+		c.state = ChargeDischarging
+		return c.state
+	}
+	if statKnown && stat {
+		c.state = ChargeCharging
+		return c.state
+	}
+
+	if mv < noBatteryMV || c.ripple() > noBatteryRipMV {
+		c.state = ChargeNoBattery
+		return c.state
+	}
+	if vbusKnown && statKnown {
+		// USB есть, зарядник не заряжает — батарея полная.
+		c.state = ChargeFull
+		return c.state
+	}
+
+	if c.state == ChargeNoBattery {
+		c.state = ChargeDischarging
+	}
+	step := c.step()
+	slope, ok := c.slope()
+	switch {
+	case step >= chargeStepMV:
+		c.state = ChargeCharging
+	case step <= -chargeStepMV && !vbusKnown:
+		c.state = ChargeDischarging
+	case !ok:
+	case slope >= chargeSlope:
+		c.state = ChargeCharging
+	case c.state == ChargeCharging && slope < flatSlope && slope > dischargeSlope && mv >= cfg.ChargedMV-fullMarginMV:
+		// Полным считается только после зарядки: иначе полная батарея в
+		// покое выглядела бы как заряженная от USB.
+		c.state = ChargeFull
+	case slope <= dischargeSlope && !vbusKnown:
+		c.state = ChargeDischarging
+	}
+	if vbusKnown && c.state == ChargeDischarging {
+		c.state = ChargeCharging
+	}
+	return c.state
+}
+
+func (c *chargeDetector) readPin(p machine.Pin, activeLow bool) (on, known bool) {
+	if p == machine.NoPin {
+		return false, false
+	}
+	return p.Get() != activeLow, true
+}
+
+// add кладёт отсчёт в окно не чаще раза в trendStep; между ними обновляется
+// только последний отсчёт.
+func (c *chargeDetector) add(mv int32, now time.Time) {
+	if c.n > 0 {
+		last := &c.samples[(c.next+trendSamples-1)%trendSamples]
+		if now.Sub(last.at) < trendStep {
+			last.mv = mv
+			return
+		}
+	}
+	c.samples[c.next] = trendSample{at: now, mv: mv}
+	c.next = (c.next + 1) % trendSamples
+	if c.n < trendSamples {
+		c.n++
+	}
+}
+
+func (c *chargeDetector) sample(i int) trendSample {
+	return c.samples[(c.next-c.n+i+trendSamples)%trendSamples]
+}
+
+// slope — наклон напряжения по методу наименьших квадратов, мВ/мин.
+func (c *chargeDetector) slope() (float64, bool) {
+	if c.n < trendMin {
+		return 0, false
+	}
+	t0 := c.sample(0).at
+	var st, sv, stt, stv float64
+	for i := 0; i < c.n; i++ {
+		s := c.sample(i)
+		t := s.at.Sub(t0).Minutes()
+		v := float64(s.mv)
+		st += t
+		sv += v
+		stt += t * t
+		stv += t * v
+	}
+	n := float64(c.n)
+	d := n*stt - st*st
+	if d == 0 {
+		return 0, false
+	}
+	return (n*stv - st*sv) / d, true
+}
+
+// step — насколько последний отсчёт отличается от среднего предыдущих.
+func (c *chargeDetector) step() int32 {
+	if c.n < 3 {
+		return 0
+	}
+	var sum int32
+	for i := 0; i < c.n-1; i++ {
+		sum += c.sample(i).mv
+	}
+	return c.sample(c.n-1).mv - sum/int32(c.n-1)
+}
+
+func (c *chargeDetector) ripple() int32 {
+	if c.n < trendMin {
+		return 0
+	}
+	lo, hi := c.sample(0).mv, c.sample(0).mv
+	for i := 1; i < c.n; i++ {
+		mv := c.sample(i).mv
+		lo, hi = min(lo, mv), max(hi, mv)
+	}
+	return hi - lo
+}
@@ -0,0 +1,103 @@
+package tdeck
+
+import "time"
+
+// BatteryLoads — токи потребителей T-Deck, мА. Значения по умолчанию —
+// грубые оценки; для точной компенсации их стоит измерить на своей плате.
+type BatteryLoads struct {
+	BaseMA          int32 // ESP32-S3 и остальная плата без радио
+	BacklightMA     int32 // подсветка дисплея на полной яркости
+	KeyboardLightMA int32 // подсветка клавиатуры на полной яркости
+	AudioMA         int32 // усилитель динамика во время воспроизведения
+	RadioTXMA       int32 // передача Wi-Fi/LoRa
+}
+
+func DefaultBatteryLoads() BatteryLoads {
+	return BatteryLoads{
+		BaseMA:          80,
+		BacklightMA:     60,
+		KeyboardLightMA: 20,
+		AudioMA:         100,
+		RadioTXMA:       250,
+	}
+}
+
+const (
+	defaultInternalResistanceMOhm = 200 // ячейка + защита + разводка
+
+	// socTau — постоянная времени фильтра процента заряда. Медленный фильтр
+	// прячет скачки от смены нагрузки, которые не учла компенсация.
+	socTau = 60 * time.Second
+)
+
+// batteryLoad — текущая нагрузка, о которой сообщило приложение.
+type batteryLoad struct {
+	backlight     uint8
+	keyboardLight uint8
+	audio         bool
+	radioTX       bool
+}
+
+// SetBacklightLoad сообщает долю тока подсветки дисплея (0–255), то есть
+// скважность ШИМ, а не уровень до кривой яркости:
+//
+//	bat.SetBacklightLoad(uint8(lilygo.BrightnessDuty(curve, level, minDuty) >> 8))
+func (b *Battery) SetBacklightLoad(level uint8) {
+	b.load.backlight = level
+}
+
+// SetKeyboardLightLoad сообщает яркость подсветки клавиатуры (0–255).
+func (b *Battery) SetKeyboardLightLoad(level uint8) {
+	b.load.keyboardLight = level
+}
+
+// SetAudioLoad сообщает, играет ли динамик.
+func (b *Battery) SetAudioLoad(on bool) {
+	b.load.audio = on
+}
+
+// SetRadioTXLoad сообщает, передаёт ли радио.
+func (b *Battery) SetRadioTXLoad(on bool) {
+	b.load.radioTX = on
+}
+
+// LoadMA возвращает оценку текущего тока разряда, мА.
+func (b *Battery) LoadMA() int32 {
+	l := b.cfg.Loads
+	ma := l.BaseMA
+	ma += l.BacklightMA * int32(b.load.backlight) / 255
+	ma += l.KeyboardLightMA * int32(b.load.keyboardLight) / 255
+	if b.load.audio {
+		ma += l.AudioMA
+	}
+	if b.load.radioTX {
+		ma += l.RadioTXMA
+	}
+	return ma
+}
+
+// compensate оценивает напряжение холостого хода: под нагрузкой напряжение
+// на клеммах ниже на I·R внутреннего сопротивления.
+func (b *Battery) compensate(mv int32) int32 {
+	return mv + b.LoadMA()*b.cfg.InternalResistanceMOhm/1000
+}
+
+// filterSoC медленно подтягивает процент к оценке по напряжению. При разряде
+// процент никогда не растёт: кратковременный рост напряжения после снятия
+// нагрузки не должен «заряжать» батарею.
+func (b *Battery) filterSoC(pct int, charging bool, now time.Time) int {
+	if b.socAt.IsZero() {
+		b.soc = float64(pct)
+		b.socAt = now
+		return pct
+	}
+	dt := now.Sub(b.socAt)
+	b.socAt = now
+	target := float64(pct)
+	if !charging && target > b.soc {
+		return int(b.soc + 0.5)
+	}
+	alpha := float64(dt) / float64(dt+socTau)
+	b.soc += alpha * (target - b.soc)
+	return int(b.soc + 0.5)
+}
@@ -0,0 +1,177 @@
+package tdeck
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCapacityMAh = 1400 // батарея из примеров T-Deck
+	defaultChargeMA    = 500  // ток заряда в фазе CC
+
+	historySamples = 20
+	historyStep    = 30 * time.Second // окно истории — 10 мин
+
+	cvStartPct  = 80  // после этого зарядник переходит в CV и ток падает
+	cvCurrentPc = 40  // средний ток в фазе CV, % от ChargeMA
+	modelConf   = 0.3 // доверие к оценке только по модели, без истории
+)
+
+type socSample struct {
+	at  time.Time
+	soc float64
+}
+
+// timeEstimator оценивает время до разряда и до полного заряда: ток берётся
+// из модели (нагрузка приложения или ток заряда) и уточняется по скорости
+// изменения заряда за последние минуты.
+type timeEstimator struct {
+	samples [historySamples]socSample
+	n       int
+	next    int
+	state   ChargeState
+}
+
+func (e *timeEstimator) add(soc float64, state ChargeState, now time.Time) {
+	if state != e.state {
+		e.n, e.next = 0, 0 // история другого режима не годится
+		e.state = state
+	}
+	if e.n > 0 {
+		last := e.samples[(e.next+historySamples-1)%historySamples]
+		if now.Sub(last.at) < historyStep {
+			return
+		}
+	}
+	e.samples[e.next] = socSample{at: now, soc: soc}
+	e.next = (e.next + 1) % historySamples
+	if e.n < historySamples {
+		e.n++
+	}
+}
+
+// rate возвращает скорость изменения заряда, %/ч, и долю заполненного окна.
+func (e *timeEstimator) rate() (pctPerHour float64, fill float64) {
+	if e.n < 3 {
+		return 0, 0
+	}
+	first := e.samples[(e.next-e.n+historySamples)%historySamples]
+	var st, sv, stt, stv, t float64
+	for i := 0; i < e.n; i++ {
+		s := e.samples[(e.next-e.n+i+historySamples)%historySamples]
+		t = s.at.Sub(first.at).Hours()
+		st += t
+		sv += s.soc
+		stt += t * t
+		stv += t * s.soc
+	}
+	n := float64(e.n)
+	d := n*stt - st*st
+	if d == 0 {
+		return 0, 0
+	}
+	// t — длительность истории: после цикла это время последнего отсчёта.
+	fill = t / (float64(historySamples-1) * historyStep.Hours())
+	if fill > 1 {
+		fill = 1
+	}
+	return (n*stv - st*sv) / d, fill
+}
+
+// historyMA возвращает ток по истории, мА, и долю заполненного окна; 0, 0 —
+// истории нет или заряд меняется не в ту сторону.
+func (e *timeEstimator) historyMA(capacityMAh int32) (float64, float64) {
+	rate, fill := e.rate()
+	ma := rate / 100 * float64(capacityMAh)
+	if e.state == ChargeDischarging {
+		ma = -ma
+	}
+	if fill == 0 || ma <= 0 {
+		return 0, 0
+	}
+	return ma, fill
+}
+
+// confidence — доверие к оценке: растёт по мере заполнения окна истории.
+func confidence(fill float64) float32 {
+	return float32(modelConf + (1-modelConf)*fill)
+}
+
+// estimate смешивает ток модели с током по истории, вес истории растёт по
+// мере заполнения окна. Возвращает ток, мА, и доверие 0–1.
+func (e *timeEstimator) estimate(modelMA float64, capacityMAh int32) (float64, float32) {
+	histMA, fill := e.historyMA(capacityMAh)
+	return fill*histMA + (1-fill)*modelMA, confidence(fill)
+}
+
+// chargeHours — время до полного заряда с процента soc при токе ma в фазе
+// постоянного тока: выше cvStartPct ток спадает до cvCurrentPc.
+func chargeHours(soc, ma float64, capMAh int32) float64 {
+	cc := float64(cvStartPct) - soc
+	cv := 100 - soc
+	if cc < 0 {
+		cc = 0
+	}
+	cv -= cc
+	return cc/100*float64(capMAh)/ma + cv/100*float64(capMAh)/(ma*cvCurrentPc/100)
+}
+
+// updateTimes заполняет TimeToEmpty, TimeToFull и TimeConfidence.
+func (b *Battery) updateTimes(r *BatteryReading, now time.Time) {
+	b.est.add(b.soc, r.State, now)
+	capMAh := b.cfg.CapacityMAh
+	if capMAh <= 0 {
+		return
+	}
+	switch r.State {
+	case ChargeDischarging:
+		ma, conf := b.est.estimate(float64(b.LoadMA()), capMAh)
+		if ma <= 0 {
+			return
+		}
+		mah := b.soc / 100 * float64(capMAh)
+		r.TimeToEmpty = time.Duration(mah / ma * float64(time.Hour))
+		r.TimeConfidence = conf
+	case ChargeCharging:
+		chg := float64(b.cfg.ChargeMA)
+		if chg <= 0 {
+			return
+		}
+		// Спад тока в CV закладывается только в номинальный ChargeMA. Ток по
+		// истории выше cvStartPct уже измерен со спадом: делить его на
+		// cvCurrentPc второй раз нельзя.
+		hours := chargeHours(b.soc, chg, capMAh)
+		histMA, fill := b.est.historyMA(capMAh)
+		if fill > 0 {
+			var histHours float64
+			if b.soc >= cvStartPct {
+				histHours = (100 - b.soc) / 100 * float64(capMAh) / histMA
+			} else {
+				// Ниже cvStartPct история измерила ток CC; спад впереди.
+				histHours = chargeHours(b.soc, histMA, capMAh)
+			}
+			hours = fill*histHours + (1-fill)*hours
+		}
+		r.TimeToFull = time.Duration(hours * float64(time.Hour))
+		r.TimeConfidence = confidence(fill)
+	}
+}
+
+// FormatBatteryTime форматирует оценку времени для экрана: "<1 min",
+// "25 min", "2 h", "1 h 40 min".
+func FormatBatteryTime(d time.Duration) string {
+	sec := int64(d / time.Second)
+	if sec < 60 {
+		return "<1 min"
+	}
+	min := sec / 60
+	if min < 60 {
+		return strconv.FormatInt(min, 10) + " min"
+	}
+	h := min / 60
+	m := min % 60
+	if m == 0 {
+		return strconv.FormatInt(h, 10) + " h"
+	}
+	return strconv.FormatInt(h, 10) + " h " + strconv.FormatInt(m, 10) + " min"
+}
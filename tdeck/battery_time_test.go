@@ -0,0 +1,41 @@
+package tdeck
+
+import (
+	"testing"
+	"time"
+)
+
+// chargeWithHistory feeds a full history window of charging at ma and
+// returns the resulting TimeToFull.
+func chargeWithHistory(startSoC, ma float64) (time.Duration, float64) {
+	cfg := DefaultBatteryConfig()
+	b := NewBattery(0, cfg)
+	rate := ma / float64(cfg.CapacityMAh) * 100 // %/h
+	now := time.Unix(0, 0)
+	var r BatteryReading
+	for i := 0; i < historySamples; i++ {
+		b.soc = startSoC + rate*now.Sub(time.Unix(0, 0)).Hours()
+		r = BatteryReading{State: ChargeCharging}
+		b.updateTimes(&r, now)
+		now = now.Add(historyStep)
+	}
+	return r.TimeToFull, b.soc
+}
+
+func TestTimeToFullMeasuredTaper(t *testing.T) {
+	// Above cvStartPct the measured current already includes the taper.
+	ttf, soc := chargeWithHistory(88, 200)
+	want := time.Duration((100 - soc) / 100 * defaultCapacityMAh / 200 * float64(time.Hour))
+	if d := ttf - want; d < -time.Minute || d > time.Minute {
+		t.Fatalf("TimeToFull = %v, want about %v", ttf, want)
+	}
+}
+
+func TestTimeToFullCCHistory(t *testing.T) {
+	// Below cvStartPct the measured CC current still gets the taper ahead.
+	ttf, soc := chargeWithHistory(40, 500)
+	want := time.Duration(chargeHours(soc, 500, defaultCapacityMAh) * float64(time.Hour))
+	if d := ttf - want; d < -time.Minute || d > time.Minute {
+		t.Fatalf("TimeToFull = %v, want about %v", ttf, want)
+	}
+}
@@ -0,0 +1,163 @@
+package tdeck
+
+import "time"
+
+// Acceleration selects how cursor gain grows with trackball speed.
+type Acceleration uint8
+
+const (
+	// AccelLinear moves a fixed number of pixels per step.
+	AccelLinear Acceleration = iota
+
+	// AccelQuadratic raises the gain in proportion to speed, so distance
+	// grows with the square of speed.
+	AccelQuadratic
+
+	// AccelBallistic follows a pointer-ballistics curve: below unity gain
+	// for slow, precise moves, unity in the middle and up to 4× for flicks.
+	AccelBallistic
+)
+
+const (
+	// DefaultSensitivity is the cursor gain in pixels per step at low speed.
+	DefaultSensitivity = 2
+
+	quadraticSpeed = 20 // steps/s at which AccelQuadratic doubles the gain
+	velocityTau    = 100 * time.Millisecond
+)
+
+// ballisticCurve maps speed (steps/s) to a gain multiplier.
+var ballisticCurve = []struct{ speed, gain float32 }{
+	{0, 0.5}, {5, 0.5}, {20, 1}, {60, 3}, {120, 4},
+}
+
+// Cursor turns trackball motion into a pointer position with acceleration,
+// sub-pixel accumulation and clamping to screen bounds.
+//
+//	cur := tdeck.NewCursor(320, 240)
+//	cur.Accel = tdeck.AccelBallistic
+//	for {
+//		x, y := cur.Move(tb.ReadMotion())
+//		...
+//	}
+type Cursor struct {
+	// Sensitivity is the gain in pixels per step at low speed.
+	Sensitivity float32
+
+	// Accel selects the acceleration curve.
+	Accel Acceleration
+
+	minX, minY, maxX, maxY float32
+	x, y                   float32
+	velocity               float32
+	last                   time.Time
+}
+
+// NewCursor creates a cursor centered on a width×height screen.
+func NewCursor(width, height int16) *Cursor {
+	c := &Cursor{Sensitivity: DefaultSensitivity}
+	c.SetBounds(0, 0, width-1, height-1)
+	c.SetPosition(width/2, height/2)
+	return c
+}
+
+// SetBounds limits the cursor to the rectangle from (minX, minY) to
+// (maxX, maxY) inclusive, e.g. the screen size minus the cursor sprite.
+func (c *Cursor) SetBounds(minX, minY, maxX, maxY int16) {
+	c.minX, c.minY = float32(minX), float32(minY)
+	c.maxX, c.maxY = float32(maxX), float32(maxY)
+	c.x, c.y = c.clamp(c.x, c.y)
+}
+
+// SetPosition moves the cursor to (x, y).
+func (c *Cursor) SetPosition(x, y int16) {
+	c.x, c.y = c.clamp(float32(x), float32(y))
+}
+
+// Position returns the cursor position in whole pixels.
+func (c *Cursor) Position() (x, y int16) {
+	return int16(c.x), int16(c.y)
+}
+
+// Velocity returns the smoothed trackball speed in steps per second.
+func (c *Cursor) Velocity() float32 {
+	return c.velocity
+}
+
+// Move applies a trackball delta, as returned by Trackball.ReadMotion, and
+// returns the new position. Call it every loop iteration, also when there
+// was no motion, so the speed estimate stays current.
+func (c *Cursor) Move(dx, dy int) (x, y int16) {
+	return c.MoveAt(dx, dy, time.Now())
+}
+
+// MoveAt is Move with an explicit timestamp.
+func (c *Cursor) MoveAt(dx, dy int, now time.Time) (x, y int16) {
+	c.updateVelocity(dx, dy, now)
+	if dx != 0 || dy != 0 {
+		g := c.Sensitivity * c.gain()
+		c.x, c.y = c.clamp(c.x+float32(dx)*g, c.y+float32(dy)*g)
+	}
+	return c.Position()
+}
+
+// updateVelocity blends the speed of this delta into an exponential moving
+// average with time constant velocityTau.
+func (c *Cursor) updateVelocity(dx, dy int, now time.Time) {
+	if c.last.IsZero() {
+		c.last = now
+		return
+	}
+	dt := now.Sub(c.last)
+	c.last = now
+	if dt <= 0 {
+		return
+	}
+	steps := abs(dx) + abs(dy)
+	speed := float32(steps) / float32(dt.Seconds())
+	alpha := float32(dt) / float32(dt+velocityTau)
+	c.velocity += alpha * (speed - c.velocity)
+}
+
+func (c *Cursor) gain() float32 {
+	v := c.velocity
+	switch c.Accel {
+	case AccelQuadratic:
+		return 1 + v/quadraticSpeed
+	case AccelBallistic:
+		last := ballisticCurve[len(ballisticCurve)-1]
+		if v >= last.speed {
+			return last.gain
+		}
+		for i := 1; i < len(ballisticCurve); i++ {
+			p0, p1 := ballisticCurve[i-1], ballisticCurve[i]
+			if v < p1.speed {
+				return p0.gain + (p1.gain-p0.gain)*(v-p0.speed)/(p1.speed-p0.speed)
+			}
+		}
+	}
+	return 1
+}
+
+func (c *Cursor) clamp(x, y float32) (float32, float32) {
+	if x < c.minX {
+		x = c.minX
+	}
+	if x > c.maxX {
+		x = c.maxX
+	}
+	if y < c.minY {
+		y = c.minY
+	}
+	if y > c.maxY {
+		y = c.maxY
+	}
+	return x, y
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
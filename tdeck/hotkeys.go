@@ -0,0 +1,279 @@
+package tdeck
+
+import "time"
+
+// Chord is a key pressed together with a set of modifiers, e.g.
+// Chord{ModShift, 's'}. Letters match regardless of case; an upper-case letter
+// in an event counts as ModShift.
+type Chord struct {
+	Mods Mod
+	Code byte
+}
+
+func (c Chord) normalize() Chord {
+	if c.Code >= 'A' && c.Code <= 'Z' {
+		c.Code += 'a' - 'A'
+		c.Mods |= ModShift
+	}
+	return c
+}
+
+const (
+	DefaultSequenceTimeout = time.Second
+	DefaultLongPress       = 600 * time.Millisecond
+)
+
+type hotkeyBinding struct {
+	seq  []Chord
+	long bool
+	fn   func()
+}
+
+// Hotkeys maps chords, chord sequences and long presses to handlers. Feed it
+// every key event, typically from the keyboard's handler:
+//
+//	hk := tdeck.NewHotkeys()
+//	hk.Bind(tdeck.Chord{tdeck.ModShift, 's'}, openSettings)
+//	hk.BindSequence([]tdeck.Chord{{0, 'q'}, {0, 'q'}}, quit)
+//	hk.BindLongPress(tdeck.Chord{0, tdeck.KeyEnter}, showMenu)
+//	kb.SetKeyHandler(hk.Handler(app.handleKey))
+//	for {
+//		kb.Poll()
+//		hk.Tick(time.Now())
+//	}
+type Hotkeys struct {
+	// SequenceTimeout is the longest pause between the chords of a sequence.
+	SequenceTimeout time.Duration
+
+	// LongPress is how long a key must be held for long-press bindings.
+	LongPress time.Duration
+
+	bindings    []hotkeyBinding
+	seq         []Chord
+	seqAt       time.Time
+	pending     []KeyEvent // events used by the sequence in progress
+	replay      []KeyEvent // events of a sequence that did not match
+	next        func(KeyEvent)
+	handler     bool  // Handler is in use; Tick replays to next
+	matched     Chord // last chord of a matched binding
+	matchedDown bool  // matched not released yet; its release is used too
+	held        Chord
+	heldAt      time.Time
+	heldDone    bool // long press of held already fired
+}
+
+// NewHotkeys creates an empty registry with the default timings.
+func NewHotkeys() *Hotkeys {
+	return &Hotkeys{
+		SequenceTimeout: DefaultSequenceTimeout,
+		LongPress:       DefaultLongPress,
+	}
+}
+
+// Bind calls fn when c is pressed.
+func (h *Hotkeys) Bind(c Chord, fn func()) {
+	h.BindSequence([]Chord{c}, fn)
+}
+
+// BindSequence calls fn when the chords are pressed one after another, each
+// within SequenceTimeout of the previous one.
+func (h *Hotkeys) BindSequence(seq []Chord, fn func()) {
+	s := make([]Chord, len(seq))
+	for i, c := range seq {
+		s[i] = c.normalize()
+	}
+	h.bindings = append(h.bindings, hotkeyBinding{seq: s, fn: fn})
+}
+
+// BindLongPress calls fn when c is held for LongPress. This relies on the
+// keyboard reporting the key as held, see KeyRepeat. All events of c are
+// then used by the registry and not passed on.
+func (h *Hotkeys) BindLongPress(c Chord, fn func()) {
+	h.bindings = append(h.bindings, hotkeyBinding{seq: []Chord{c.normalize()}, long: true, fn: fn})
+}
+
+// Handle processes a key event and reports whether it was used by a binding:
+// completing one, being part of a sequence in progress, or repeating or
+// releasing the last key of a completed binding. Events of a sequence that
+// then does not match are given back through Replay, so typing "ga" with a
+// "g g" binding does not lose the "g".
+func (h *Hotkeys) Handle(ev KeyEvent) bool {
+	c := Chord{Mods: ev.Mods, Code: ev.Code}.normalize()
+	switch {
+	case !ev.Pressed:
+		if h.release(c, ev.Time) {
+			return true
+		}
+		if h.matchedDown && c == h.matched {
+			h.matchedDown = false
+			return true
+		}
+		return h.hold(c, ev)
+	case ev.Repeat:
+		if h.repeat(c, ev.Time) {
+			return true
+		}
+		if h.matchedDown && c == h.matched {
+			return true
+		}
+		return h.hold(c, ev)
+	}
+	h.matchedDown = false
+	h.held, h.heldAt, h.heldDone = c, ev.Time, false
+	longBound := h.find([]Chord{c}, true) != nil
+	if len(h.seq) > 0 && ev.Time.Sub(h.seqAt) > h.SequenceTimeout {
+		h.abandon()
+	}
+	h.seq = append(h.seq, c)
+	h.seqAt = ev.Time
+	for {
+		if b := h.find(h.seq, false); b != nil {
+			h.seq = h.seq[:0]
+			h.pending = h.pending[:0]
+			// The release of the last key belongs to the binding too.
+			h.matched, h.matchedDown = c, true
+			b.fn()
+			return true
+		}
+		if h.isPrefix(h.seq) {
+			h.pending = append(h.pending, ev)
+			return true
+		}
+		if len(h.seq) == 1 {
+			h.seq = h.seq[:0]
+			return longBound
+		}
+		// Not part of any sequence: give the prefix back and start over
+		// from this chord.
+		h.abandon()
+		h.seq = append(h.seq, c)
+	}
+}
+
+// hold keeps the release or repeat of a chord of the sequence in progress
+// with the sequence, so that a replay keeps the events in order.
+func (h *Hotkeys) hold(c Chord, ev KeyEvent) bool {
+	for _, s := range h.seq {
+		if s == c {
+			h.pending = append(h.pending, ev)
+			return true
+		}
+	}
+	return false
+}
+
+// abandon drops the sequence in progress and queues its events for Replay.
+func (h *Hotkeys) abandon() {
+	h.seq = h.seq[:0]
+	h.replay = append(h.replay, h.pending...)
+	h.pending = h.pending[:0]
+}
+
+// Tick gives up a sequence in progress once SequenceTimeout has passed since
+// its last chord, so that a key starting a sequence is not held back until
+// the next key press. Call it regularly, e.g. after every Keyboard.Poll. With
+// Handler the events of the sequence go to its next function; with Handle,
+// fetch them with Replay.
+func (h *Hotkeys) Tick(now time.Time) {
+	if len(h.seq) > 0 && now.Sub(h.seqAt) > h.SequenceTimeout {
+		h.abandon()
+	}
+	if !h.handler {
+		return
+	}
+	if h.next == nil {
+		h.replay = h.replay[:0]
+		return
+	}
+	h.Replay(h.next)
+}
+
+// Replay passes the events of sequences that did not match to fn, oldest
+// first. Handler and Tick do this themselves; call it after Handle and Tick
+// when using Handle directly.
+func (h *Hotkeys) Replay(fn func(KeyEvent)) {
+	for _, ev := range h.replay {
+		fn(ev)
+	}
+	h.replay = h.replay[:0]
+}
+
+// Handler returns a key handler that passes events not used by a binding on
+// to next, preceded by any events of a sequence that did not match. next may
+// be nil.
+func (h *Hotkeys) Handler(next func(KeyEvent)) func(KeyEvent) {
+	h.next, h.handler = next, true
+	return func(ev KeyEvent) {
+		used := h.Handle(ev)
+		if next == nil {
+			h.replay = h.replay[:0]
+			return
+		}
+		h.Replay(next)
+		if !used {
+			next(ev)
+		}
+	}
+}
+
+func (h *Hotkeys) repeat(c Chord, now time.Time) bool {
+	if c != h.held {
+		return false
+	}
+	b := h.find([]Chord{c}, true)
+	if b == nil {
+		return false
+	}
+	if !h.heldDone && now.Sub(h.heldAt) >= h.LongPress {
+		h.heldDone = true
+		b.fn()
+	}
+	return true
+}
+
+func (h *Hotkeys) release(c Chord, now time.Time) bool {
+	if c != h.held {
+		return false
+	}
+	h.held = Chord{}
+	b := h.find([]Chord{c}, true)
+	if b == nil {
+		return false
+	}
+	if !h.heldDone && now.Sub(h.heldAt) >= h.LongPress {
+		b.fn()
+	}
+	h.heldDone = false
+	return true
+}
+
+func (h *Hotkeys) find(seq []Chord, long bool) *hotkeyBinding {
+	for i := range h.bindings {
+		b := &h.bindings[i]
+		if b.long == long && chordsEqual(b.seq, seq) {
+			return b
+		}
+	}
+	return nil
+}
+
+func (h *Hotkeys) isPrefix(seq []Chord) bool {
+	for _, b := range h.bindings {
+		if !b.long && len(b.seq) > len(seq) && chordsEqual(b.seq[:len(seq)], seq) {
+			return true
+		}
+	}
+	return false
+}
+
+func chordsEqual(a, b []Chord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
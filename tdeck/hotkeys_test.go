@@ -0,0 +1,109 @@
+package tdeck
+
+import (
+	"testing"
+	"time"
+)
+
+type keyScript struct {
+	now time.Time
+	h   func(KeyEvent)
+}
+
+func (s *keyScript) tap(code byte) {
+	s.h(KeyEvent{Code: code, Pressed: true, Time: s.now})
+	s.now = s.now.Add(50 * time.Millisecond)
+	s.h(KeyEvent{Code: code, Time: s.now})
+	s.now = s.now.Add(50 * time.Millisecond)
+}
+
+func TestHotkeysReplayPrefix(t *testing.T) {
+	hk := NewHotkeys()
+	fired := 0
+	hk.BindSequence([]Chord{{Code: 'g'}, {Code: 'g'}}, func() { fired++ })
+
+	var got []KeyEvent
+	s := &keyScript{now: time.Unix(0, 0), h: hk.Handler(func(ev KeyEvent) { got = append(got, ev) })}
+
+	s.tap('g')
+	s.tap('a')
+	want := []struct {
+		code    byte
+		pressed bool
+	}{{'g', true}, {'g', false}, {'a', true}, {'a', false}}
+	if len(got) != len(want) {
+		t.Fatalf("passed on %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Code != w.code || got[i].Pressed != w.pressed {
+			t.Fatalf("event %d = %c pressed=%v, want %c pressed=%v", i, got[i].Code, got[i].Pressed, w.code, w.pressed)
+		}
+	}
+
+	got = got[:0]
+	s.tap('g')
+	s.tap('g')
+	if fired != 1 {
+		t.Fatalf("sequence fired %d times, want 1", fired)
+	}
+	if len(got) != 0 {
+		t.Fatalf("events of a matched sequence passed on: %+v", got)
+	}
+	s.tap('a')
+	if len(got) != 2 || got[0].Code != 'a' || got[1].Code != 'a' {
+		t.Fatalf("events after the sequence: %+v, want the press and release of a", got)
+	}
+}
+
+func TestHotkeysBindingRelease(t *testing.T) {
+	hk := NewHotkeys()
+	hk.Bind(Chord{Code: KeySpeaker}, func() {})
+	var got []KeyEvent
+	h := hk.Handler(func(ev KeyEvent) { got = append(got, ev) })
+	now := time.Unix(0, 0)
+	h(KeyEvent{Code: KeySpeaker, Pressed: true, Time: now})
+	h(KeyEvent{Code: KeySpeaker, Pressed: true, Repeat: true, Time: now.Add(500 * time.Millisecond)})
+	h(KeyEvent{Code: KeySpeaker, Time: now.Add(600 * time.Millisecond)})
+	if len(got) != 0 {
+		t.Fatalf("events of a bound key passed on: %+v", got)
+	}
+}
+
+func TestHotkeysTickFlushesPrefix(t *testing.T) {
+	hk := NewHotkeys()
+	hk.BindSequence([]Chord{{Code: 'g'}, {Code: 'g'}}, func() { t.Fatal("fired after timeout") })
+
+	var got []KeyEvent
+	s := &keyScript{now: time.Unix(0, 0), h: hk.Handler(func(ev KeyEvent) { got = append(got, ev) })}
+	s.tap('g')
+	hk.Tick(s.now)
+	if len(got) != 0 {
+		t.Fatalf("prefix passed on before the timeout: %+v", got)
+	}
+	hk.Tick(s.now.Add(DefaultSequenceTimeout))
+	if len(got) != 2 || got[0].Code != 'g' || !got[0].Pressed || got[1].Pressed {
+		t.Fatalf("after the timeout: %+v, want the press and release of g", got)
+	}
+	hk.Tick(s.now.Add(2 * DefaultSequenceTimeout))
+	if len(got) != 2 {
+		t.Fatalf("prefix replayed twice: %+v", got)
+	}
+}
+
+func TestHotkeysReplayAfterTimeout(t *testing.T) {
+	hk := NewHotkeys()
+	hk.BindSequence([]Chord{{Code: 'g'}, {Code: 'g'}}, func() { t.Fatal("fired after timeout") })
+
+	var presses []byte
+	s := &keyScript{now: time.Unix(0, 0), h: hk.Handler(func(ev KeyEvent) {
+		if ev.Pressed {
+			presses = append(presses, ev.Code)
+		}
+	})}
+	s.tap('g')
+	s.now = s.now.Add(2 * DefaultSequenceTimeout)
+	s.tap('x')
+	if string(presses) != "gx" {
+		t.Fatalf("presses %q, want %q", presses, "gx")
+	}
+}
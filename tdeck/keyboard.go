@@ -4,6 +4,7 @@ package tdeck
 
 import (
 	"machine"
+	"sync/atomic"
 	"time"
 
 	drivers "github.com/dimajolkin/tinygo-lilygo-drivers"
@@ -18,10 +19,29 @@ const (
 )
 
 type Keyboard struct {
-	bus      drivers.I2C
-	addr     uint16
-	powerPin machine.Pin
-	readBuf  [1]byte
+	bus       drivers.I2C
+	addr      uint16
+	powerPin  machine.Pin
+	intPin    machine.Pin
+	pending   uint32 // set from the interrupt handler
+	handler   func(KeyEvent)
+	curve     drivers.BrightnessCurve
+	minDuty   uint16
+	repeat    KeyRepeat
+	held      heldKey
+	keymap    *Keymap
+	events    queue[KeyEvent]
+	stats     KeyboardStats
+	maxErrors int
+
+	brightness   uint8 // level set by the user
+	output       uint8 // level currently shown
+	fade         kbFade
+	autoOff      time.Duration
+	idleOff      bool // backlight turned off by auto-off
+	lastActivity time.Time
+
+	readBuf [1]byte
 }
 
 func New(bus drivers.I2C, powerPin machine.Pin) *Keyboard {
@@ -29,22 +49,43 @@ func New(bus drivers.I2C, powerPin machine.Pin) *Keyboard {
 }
 
 func NewWithAddress(bus drivers.I2C, addr uint16, powerPin machine.Pin) *Keyboard {
+	return NewWithInterrupt(bus, addr, powerPin, machine.NoPin)
+}
+
+// NewWithInterrupt creates a keyboard that reads the bus only after the
+// keyboard controller pulls intPin low (KeyboardINT on the T-Deck), instead
+// of on every Poll. intPin may be machine.NoPin to poll unconditionally.
+func NewWithInterrupt(bus drivers.I2C, addr uint16, powerPin, intPin machine.Pin) *Keyboard {
 	if powerPin != machine.NoPin {
 		powerPin.Configure(machine.PinConfig{Mode: machine.PinOutput})
 		powerPin.Low()
 	}
-	return &Keyboard{
-		bus:      bus,
-		addr:     addr,
-		powerPin: powerPin,
+	k := &Keyboard{
+		bus:       bus,
+		addr:      addr,
+		powerPin:  powerPin,
+		intPin:    intPin,
+		repeat:    DefaultKeyRepeat(),
+		keymap:    KeymapUS,
+		events:    newQueue[KeyEvent](keyQueueSize),
+		maxErrors: DefaultMaxErrors,
 	}
+	if intPin != machine.NoPin {
+		intPin.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+		intPin.SetInterrupt(machine.PinFalling, func(machine.Pin) {
+			atomic.StoreUint32(&k.pending, 1)
+		})
+	}
+	return k
 }
 
-func (k *Keyboard) PowerOn() {
+// PowerOn powers the keyboard and waits until its controller answers, at
+// most DefaultReadyTimeout.
+func (k *Keyboard) PowerOn() error {
 	if k.powerPin != machine.NoPin {
 		k.powerPin.High()
-		time.Sleep(500 * time.Millisecond)
 	}
+	return k.WaitReady(DefaultReadyTimeout)
 }
 
 func (k *Keyboard) PowerOff() {
@@ -53,11 +94,44 @@ func (k *Keyboard) PowerOff() {
 	}
 }
 
+// SetBrightnessCurve sets how levels passed to SetBrightness and
+// SetDefaultBrightness map onto the backlight register, the same way as
+// st7789.Device.SetBacklightCurve. A nil curve writes levels as is.
+func (k *Keyboard) SetBrightnessCurve(curve drivers.BrightnessCurve, minDuty uint16) {
+	k.curve = curve
+	k.minDuty = minDuty
+}
+
+// level maps a brightness level onto the register value through the curve.
+func (k *Keyboard) level(value uint8) uint8 {
+	if k.curve == nil || value == 0 {
+		return value
+	}
+	v := uint8(drivers.BrightnessDuty(k.curve, value, k.minDuty) >> 8)
+	if v == 0 {
+		v = 1
+	}
+	return v
+}
+
+// SetBrightness sets the keyboard backlight level, 0 turns it off.
 func (k *Keyboard) SetBrightness(value uint8) error {
-	return k.bus.Tx(k.addr, []byte{regBrightness, value}, nil)
+	k.brightness = value
+	k.idleOff = false
+	k.fade.active = false
+	return k.writeBrightness(value)
+}
+
+func (k *Keyboard) writeBrightness(value uint8) error {
+	err := k.bus.Tx(k.addr, []byte{regBrightness, k.level(value)}, nil)
+	if err == nil {
+		k.output = value
+	}
+	return err
 }
 
 func (k *Keyboard) SetDefaultBrightness(value uint8) error {
+	value = k.level(value)
 	if value < minDefaultBrightness {
 		value = minDefaultBrightness
 	}
@@ -66,11 +140,15 @@ func (k *Keyboard) SetDefaultBrightness(value uint8) error {
 
 var emptyWrite = []byte{}
 
+// ReadKey reads the last key code from the keyboard, 0 if none was pressed.
+// Failed reads are counted, see SetRecovery and Stats.
 func (k *Keyboard) ReadKey() (byte, error) {
 	k.readBuf[0] = 0
 	err := k.bus.Tx(k.addr, emptyWrite, k.readBuf[:])
 	if err != nil {
+		k.readError()
 		return 0, err
 	}
+	k.stats.ConsecutiveErrors = 0
 	return k.readBuf[0], nil
 }
@@ -0,0 +1,84 @@
+package tdeck
+
+import "time"
+
+const (
+	autoOffFade = 500 * time.Millisecond
+	wakeFade    = 150 * time.Millisecond
+)
+
+// kbFade is a brightness transition advanced by Poll.
+type kbFade struct {
+	active bool
+	from   uint8
+	to     uint8
+	start  time.Time
+	dur    time.Duration
+}
+
+// Brightness returns the backlight level last set with SetBrightness or
+// FadeBrightness. It is kept while the backlight is off, so it can be
+// restored.
+func (k *Keyboard) Brightness() uint8 {
+	return k.brightness
+}
+
+// BacklightOn reports whether the driver last left the keyboard backlight
+// lit. The firmware handles Alt+B itself and the driver gets no reliable
+// event for it, so a toggle made with Alt+B is not tracked; SetBrightness or
+// FadeBrightness bring the backlight back in line with this state.
+func (k *Keyboard) BacklightOn() bool {
+	return k.output != 0
+}
+
+// FadeBrightness moves the backlight to value over duration d. The fade is
+// advanced by Poll, which must keep being called.
+func (k *Keyboard) FadeBrightness(value uint8, d time.Duration) {
+	k.brightness = value
+	k.idleOff = false
+	k.startFade(value, d)
+}
+
+// SetAutoOff turns the keyboard backlight off after d without key presses
+// and back on at the next press. 0 disables it.
+func (k *Keyboard) SetAutoOff(d time.Duration) {
+	k.autoOff = d
+	k.lastActivity = time.Now()
+}
+
+func (k *Keyboard) startFade(to uint8, d time.Duration) {
+	k.fade = kbFade{active: true, from: k.output, to: to, start: time.Now(), dur: d}
+}
+
+// updateBacklight advances fades and the auto-off timer. It is called by Poll.
+func (k *Keyboard) updateBacklight(now time.Time) {
+	if k.autoOff > 0 && !k.idleOff && k.output != 0 && now.Sub(k.lastActivity) >= k.autoOff {
+		k.idleOff = true
+		k.startFade(0, autoOffFade)
+	}
+	if !k.fade.active {
+		return
+	}
+	f := &k.fade
+	elapsed := now.Sub(f.start)
+	level := f.to
+	if elapsed < f.dur {
+		// int64: on the ESP32-S3 int is 32 bits and elapsed is in nanoseconds.
+		level = uint8(int64(f.from) + (int64(f.to)-int64(f.from))*int64(elapsed)/int64(f.dur))
+	} else {
+		f.active = false
+	}
+	if level != k.output {
+		k.writeBrightness(level)
+	}
+}
+
+// keyActivity is called for every key press: it restores a backlight that
+// was turned off for inactivity.
+func (k *Keyboard) keyActivity(now time.Time) {
+	k.lastActivity = now
+	if k.idleOff {
+		k.idleOff = false
+		k.startFade(k.brightness, wakeFade)
+	}
+}
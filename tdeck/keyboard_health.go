@@ -0,0 +1,87 @@
+package tdeck
+
+import (
+	"errors"
+	"machine"
+	"time"
+)
+
+const (
+	// DefaultReadyTimeout is how long PowerOn waits for the keyboard
+	// controller to answer.
+	DefaultReadyTimeout = 2 * time.Second
+
+	// DefaultMaxErrors is the number of consecutive bus errors after which
+	// the keyboard is power-cycled. Recovery is off by default: on the T-Deck
+	// the power pin is also the display reset, see SetRecovery.
+	DefaultMaxErrors = 0
+
+	readyPollInterval = 20 * time.Millisecond
+	powerCycleDelay   = 100 * time.Millisecond
+)
+
+var ErrKeyboardTimeout = errors.New("tdeck: keyboard did not answer in time")
+
+// KeyboardStats are the bus error counters of a Keyboard.
+type KeyboardStats struct {
+	Errors            uint32 // failed reads since creation
+	ConsecutiveErrors uint32 // failed reads since the last successful one
+	Recoveries        uint32 // power cycles done to recover the controller
+}
+
+// Probe checks whether the keyboard controller answers at its address. A key
+// code read while probing is not lost: it is handled as if read by Poll.
+func (k *Keyboard) Probe() error {
+	k.readBuf[0] = 0
+	if err := k.bus.Tx(k.addr, emptyWrite, k.readBuf[:]); err != nil {
+		return err
+	}
+	if k.readBuf[0] != 0 {
+		k.update(k.readBuf[0], time.Now())
+	}
+	return nil
+}
+
+// WaitReady probes the keyboard until it answers or timeout passes.
+func (k *Keyboard) WaitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if k.Probe() == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrKeyboardTimeout
+		}
+		time.Sleep(readyPollInterval)
+	}
+}
+
+// SetRecovery sets after how many consecutive bus errors ReadKey power-cycles
+// the keyboard through its power pin. 0 disables recovery. The power cycle
+// blocks ReadKey for up to powerCycleDelay plus DefaultReadyTimeout. On the
+// T-Deck the power pin (GPIO10) also resets the display and powers the touch
+// panel, so enable recovery only if the application initializes them again
+// after Stats().Recoveries changes.
+func (k *Keyboard) SetRecovery(maxErrors int) {
+	k.maxErrors = maxErrors
+}
+
+// Stats returns the bus error counters.
+func (k *Keyboard) Stats() KeyboardStats {
+	return k.stats
+}
+
+// readError records a failed read and power-cycles the keyboard once too
+// many reads in a row have failed.
+func (k *Keyboard) readError() {
+	k.stats.Errors++
+	k.stats.ConsecutiveErrors++
+	if k.maxErrors <= 0 || k.powerPin == machine.NoPin || int(k.stats.ConsecutiveErrors) < k.maxErrors {
+		return
+	}
+	k.stats.Recoveries++
+	k.stats.ConsecutiveErrors = 0
+	k.PowerOff()
+	time.Sleep(powerCycleDelay)
+	k.PowerOn()
+}
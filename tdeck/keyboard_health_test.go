@@ -0,0 +1,92 @@
+package tdeck
+
+import (
+	"errors"
+	"machine"
+	"testing"
+)
+
+var errBus = errors.New("bus error")
+
+// fakeKeyboardBus fails the next fail transactions and then returns the
+// queued key codes, one per read.
+type fakeKeyboardBus struct {
+	fail  int
+	keys  []byte
+	reads int
+}
+
+func (b *fakeKeyboardBus) Tx(addr uint16, w, r []byte) error {
+	if len(r) == 0 {
+		return nil
+	}
+	b.reads++
+	if b.fail > 0 {
+		b.fail--
+		return errBus
+	}
+	r[0] = 0
+	if len(b.keys) > 0 {
+		r[0] = b.keys[0]
+		b.keys = b.keys[1:]
+	}
+	return nil
+}
+
+func TestKeyboardRecoveryOffByDefault(t *testing.T) {
+	bus := &fakeKeyboardBus{fail: 20}
+	k := New(bus, machine.GPIO10)
+	for i := 0; i < 20; i++ {
+		if _, err := k.ReadKey(); err == nil {
+			t.Fatalf("read %d: expected error", i)
+		}
+	}
+	st := k.Stats()
+	if st.Recoveries != 0 || st.Errors != 20 || st.ConsecutiveErrors != 20 {
+		t.Fatalf("stats = %+v, want 20 errors and no recoveries", st)
+	}
+}
+
+func TestKeyboardRecoveryThreshold(t *testing.T) {
+	bus := &fakeKeyboardBus{fail: 5}
+	k := New(bus, machine.GPIO10)
+	k.SetRecovery(3)
+
+	for i := 0; i < 2; i++ {
+		k.ReadKey()
+	}
+	if st := k.Stats(); st.Recoveries != 0 || st.ConsecutiveErrors != 2 {
+		t.Fatalf("below threshold: stats = %+v", st)
+	}
+	// The third error power-cycles; PowerOn probes until the bus answers,
+	// which takes the two remaining failures.
+	k.ReadKey()
+	st := k.Stats()
+	if st.Recoveries != 1 || st.ConsecutiveErrors != 0 || st.Errors != 3 {
+		t.Fatalf("at threshold: stats = %+v", st)
+	}
+	if bus.fail != 0 {
+		t.Fatalf("PowerOn left %d failures unprobed", bus.fail)
+	}
+
+	bus.fail = 1
+	k.ReadKey()
+	if _, err := k.ReadKey(); err != nil {
+		t.Fatal(err)
+	}
+	if st := k.Stats(); st.ConsecutiveErrors != 0 || st.Errors != 4 || st.Recoveries != 1 {
+		t.Fatalf("after success: stats = %+v", st)
+	}
+}
+
+func TestKeyboardRecoveryNeedsPowerPin(t *testing.T) {
+	bus := &fakeKeyboardBus{fail: 10}
+	k := New(bus, machine.NoPin)
+	k.SetRecovery(2)
+	for i := 0; i < 10; i++ {
+		k.ReadKey()
+	}
+	if st := k.Stats(); st.Recoveries != 0 {
+		t.Fatalf("recovered without a power pin: %+v", st)
+	}
+}
@@ -0,0 +1,188 @@
+package tdeck
+
+import (
+	"machine"
+	"sync/atomic"
+	"time"
+)
+
+// Mod is a set of modifier keys active for a key event. Only Shift is known
+// to the driver: the firmware reports it as upper-case letters.
+type Mod uint8
+
+const (
+	ModShift Mod = 1 << iota
+)
+
+// KeyEvent is a key press, repeat or release read from the keyboard.
+type KeyEvent struct {
+	Code    byte      // key code: the firmware code or a Key* constant
+	Rune    rune      // character for the key, 0 if it has none
+	Mods    Mod       // modifiers active for the key
+	Pressed bool      // true for presses and repeats, false for the release
+	Repeat  bool      // true for auto-repeat events of a held key
+	Time    time.Time // when the event was read
+}
+
+// KeyRepeat configures how held keys are reported.
+//
+// The keyboard firmware sends one code per key press and never a release, so
+// every code read is a new press. A release event is generated Release after
+// the latest code. Codes of the same key that keep arriving less than Release
+// apart once Delay has passed since the press are taken as the firmware's own
+// auto-repeat: they produce repeat events at most every Rate instead of new
+// presses. A zero Rate disables repeat events.
+type KeyRepeat struct {
+	Delay   time.Duration
+	Rate    time.Duration
+	Release time.Duration
+}
+
+// DefaultKeyRepeat returns the repeat settings used by New.
+func DefaultKeyRepeat() KeyRepeat {
+	return KeyRepeat{
+		Delay:   400 * time.Millisecond,
+		Rate:    60 * time.Millisecond,
+		Release: 80 * time.Millisecond,
+	}
+}
+
+const keyQueueSize = 16
+
+// heldKey tracks the key that is currently down.
+type heldKey struct {
+	down     bool
+	code     byte      // raw code
+	ev       KeyEvent  // translated press event
+	since    time.Time // first report
+	last     time.Time // latest report
+	lastEmit time.Time // latest press or repeat event
+}
+
+// SetKeyRepeat changes how held keys are reported.
+func (k *Keyboard) SetKeyRepeat(r KeyRepeat) {
+	k.repeat = r
+}
+
+// Poll reads all codes the keyboard has buffered and queues the resulting
+// events. Call it
+// regularly, e.g. from the main loop, and fetch events with NextEvent. With an
+// interrupt pin the bus is only read after an interrupt, so polling often is
+// cheap. If a handler is set with SetKeyHandler, queued events are passed to
+// it instead.
+func (k *Keyboard) Poll() error {
+	now := time.Now()
+	read := false
+	// pending is cleared before draining, so an edge raised during the drain
+	// is kept for the next Poll.
+	if k.intPin == machine.NoPin || atomic.SwapUint32(&k.pending, 0) != 0 {
+		// One interrupt edge may stand for several buffered codes: read until
+		// the controller has nothing left, at most a queue's worth.
+		for i := 0; i < keyQueueSize; i++ {
+			code, err := k.ReadKey()
+			if err != nil {
+				return err
+			}
+			if code == 0 {
+				break
+			}
+			k.update(code, now)
+			read = true
+		}
+	}
+	if !read {
+		k.update(0, now)
+	}
+	k.updateBacklight(now)
+	if k.handler != nil {
+		for {
+			ev, ok := k.events.pop()
+			if !ok {
+				break
+			}
+			k.handler(ev)
+		}
+	}
+	return nil
+}
+
+// SetKeyHandler sets a function that Poll calls for every key event. Pass nil
+// to queue events for NextEvent again.
+func (k *Keyboard) SetKeyHandler(h func(KeyEvent)) {
+	k.handler = h
+}
+
+// Run polls the keyboard every interval and sends the events to ch. It never
+// returns; start it in its own goroutine:
+//
+//	events := make(chan tdeck.KeyEvent, 8)
+//	go kb.Run(events, 5*time.Millisecond)
+func (k *Keyboard) Run(ch chan<- KeyEvent, interval time.Duration) {
+	for {
+		if k.Poll() == nil {
+			for {
+				ev, ok := k.events.pop()
+				if !ok {
+					break
+				}
+				ch <- ev
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// NextEvent returns the oldest queued key event. The queue holds the last 16
+// events; older ones are dropped if they are not read in time.
+func (k *Keyboard) NextEvent() (KeyEvent, bool) {
+	return k.events.pop()
+}
+
+// update turns a raw report (0 for none) into press, repeat and release events.
+func (k *Keyboard) update(code byte, now time.Time) {
+	h := &k.held
+	if code == 0 {
+		if h.down && now.Sub(h.last) >= k.repeat.Release {
+			h.down = false
+			k.emit(h.ev, false, false, now)
+		}
+		return
+	}
+	// Before Delay the same code is a new press: fast double letters must
+	// not merge into one.
+	if h.down && h.code == code && now.Sub(h.last) < k.repeat.Release && now.Sub(h.since) >= k.repeat.Delay {
+		h.last = now
+		if k.repeat.Rate > 0 && now.Sub(h.lastEmit) >= k.repeat.Rate {
+			h.lastEmit = now
+			k.emit(h.ev, true, true, now)
+		}
+		return
+	}
+	if h.down {
+		k.emit(h.ev, false, false, now)
+	}
+	*h = heldKey{down: true, code: code, ev: k.translate(code), since: now, last: now, lastEmit: now}
+	k.emit(h.ev, true, false, now)
+	k.keyActivity(now)
+}
+
+// translate fills in the rune and modifiers for a newly pressed raw code.
+func (k *Keyboard) translate(code byte) KeyEvent {
+	ev := KeyEvent{Code: code}
+	if code >= 'A' && code <= 'Z' {
+		ev.Mods = ModShift
+	}
+	km := k.keymap
+	if km == nil {
+		km = KeymapUS
+	}
+	ev.Rune = km.Translate(code, ev.Mods)
+	return ev
+}
+
+func (k *Keyboard) emit(ev KeyEvent, pressed, repeat bool, now time.Time) {
+	ev.Pressed = pressed
+	ev.Repeat = repeat
+	ev.Time = now
+	k.events.push(ev)
+}
@@ -0,0 +1,52 @@
+package tdeck
+
+import (
+	"machine"
+	"testing"
+)
+
+func pressedCodes(k *Keyboard) []byte {
+	var codes []byte
+	for {
+		ev, ok := k.NextEvent()
+		if !ok {
+			return codes
+		}
+		if ev.Pressed && !ev.Repeat {
+			codes = append(codes, ev.Code)
+		}
+	}
+}
+
+func TestPollDrainsBufferedCodes(t *testing.T) {
+	bus := &fakeKeyboardBus{keys: []byte("abc")}
+	k := NewWithInterrupt(bus, DefaultAddress, machine.NoPin, machine.GPIO16)
+	k.pending = 1 // one interrupt edge for all three codes
+	if err := k.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(pressedCodes(k)); got != "abc" {
+		t.Fatalf("pressed %q, want %q", got, "abc")
+	}
+	if k.pending != 0 {
+		t.Fatal("pending not cleared")
+	}
+	reads := bus.reads
+	if err := k.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if bus.reads != reads {
+		t.Fatal("Poll read the bus without an interrupt")
+	}
+}
+
+func TestDoubleLetterIsTwoPresses(t *testing.T) {
+	bus := &fakeKeyboardBus{keys: []byte("ll")}
+	k := New(bus, machine.NoPin)
+	if err := k.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(pressedCodes(k)); got != "ll" {
+		t.Fatalf("pressed %q, want %q", got, "ll")
+	}
+}
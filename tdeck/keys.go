@@ -0,0 +1,120 @@
+package tdeck
+
+import (
+	"strconv"
+	"unicode"
+)
+
+// Key codes of the special keys. Printable keys arrive as their ASCII code;
+// the firmware applies Shift itself and sends upper-case letters. Alt and Sym
+// are handled by the firmware too and have no code of their own here.
+//
+// The arrow codes are not sent by the keyboard: Navigator produces them from
+// trackball motion.
+const (
+	KeyNone      byte = 0x00
+	KeySpeaker   byte = 0x04
+	KeyBackspace byte = 0x08
+	KeyTab       byte = 0x09
+	KeyEnter     byte = 0x0D
+	KeyEscape    byte = 0x1B
+	KeySpace     byte = 0x20
+	KeyDelete    byte = 0x7F
+	KeyRight     byte = 0xD7
+	KeyLeft      byte = 0xD8
+	KeyDown      byte = 0xD9
+	KeyUp        byte = 0xDA
+)
+
+var keyNames = map[byte]string{
+	KeyNone:      "None",
+	KeySpeaker:   "Speaker",
+	KeyBackspace: "Backspace",
+	KeyTab:       "Tab",
+	KeyEnter:     "Enter",
+	KeyEscape:    "Escape",
+	KeySpace:     "Space",
+	KeyDelete:    "Delete",
+	KeyRight:     "Right",
+	KeyLeft:      "Left",
+	KeyDown:      "Down",
+	KeyUp:        "Up",
+}
+
+// KeyName returns a readable name for a key code: the name of a special key,
+// the character of a printable key, or the code in hex.
+func KeyName(code byte) string {
+	if name, ok := keyNames[code]; ok {
+		return name
+	}
+	if code > 0x20 && code < 0x7F {
+		return string(rune(code))
+	}
+	return "0x" + strconv.FormatUint(uint64(code), 16)
+}
+
+// Keymap translates key codes into runes. A keymap may overlay a base keymap:
+// codes it does not define are looked up in the base.
+type Keymap struct {
+	Name string
+	Base *Keymap
+
+	// Runes maps lower-case codes to runes; upper-case codes and Shift give
+	// the upper-case rune.
+	Runes map[byte]rune
+}
+
+// KeymapUS is the US QWERTY layout printed on the keyboard.
+var KeymapUS = &Keymap{Name: "US"}
+
+// KeymapRU is the Russian ЙЦУКЕН layout over KeymapUS. Х, Ъ, Ж, Э, Б, Ю and
+// Ё have no key of their own; a keymap over KeymapRU can put them on codes
+// the application does not need otherwise.
+var KeymapRU = &Keymap{
+	Name: "RU",
+	Base: KeymapUS,
+	Runes: map[byte]rune{
+		'q': 'й', 'w': 'ц', 'e': 'у', 'r': 'к', 't': 'е', 'y': 'н', 'u': 'г', 'i': 'ш', 'o': 'щ', 'p': 'з',
+		'a': 'ф', 's': 'ы', 'd': 'в', 'f': 'а', 'g': 'п', 'h': 'р', 'j': 'о', 'k': 'л', 'l': 'д',
+		'z': 'я', 'x': 'ч', 'c': 'с', 'v': 'м', 'b': 'и', 'n': 'т', 'm': 'ь',
+	},
+}
+
+// Translate returns the rune for a raw code pressed with mods, or 0 if the
+// key has none.
+func (m *Keymap) Translate(code byte, mods Mod) rune {
+	lower := code
+	shift := mods&ModShift != 0
+	if code >= 'A' && code <= 'Z' {
+		lower = code + 'a' - 'A'
+		shift = true
+	}
+	r := m.lookup(lower)
+	if shift {
+		r = unicode.ToUpper(r)
+	}
+	return r
+}
+
+func (m *Keymap) lookup(code byte) rune {
+	for km := m; km != nil; km = km.Base {
+		if r, ok := km.Runes[code]; ok {
+			return r
+		}
+	}
+	if code >= 0x20 && code < 0x7F {
+		return rune(code)
+	}
+	return 0
+}
+
+// SetKeymap selects the keymap used to fill KeyEvent.Rune.
+// It can be switched at any time, e.g. from a hotkey.
+func (k *Keyboard) SetKeymap(m *Keymap) {
+	k.keymap = m
+}
+
+// Keymap returns the keymap in use.
+func (k *Keyboard) Keymap() *Keymap {
+	return k.keymap
+}
@@ -0,0 +1,249 @@
+package tdeck
+
+import (
+	"image/color"
+	"unicode/utf8"
+)
+
+// TextRenderer is a target LineEditor can draw on, such as st7789.Device.
+// Glyphs are assumed to be 6×8 pixels times the scale, like the st7789 5×7
+// font with spacing.
+type TextRenderer interface {
+	FillRectangle(x, y, width, height int16, c color.RGBA) error
+	DrawString(x, y int16, s string, fg color.RGBA, scale int)
+}
+
+const (
+	glyphW = 6
+	glyphH = 8
+
+	defaultHistory = 8
+)
+
+// LineEditor is a single-line text input fed with key events. It supports
+// cursor movement (Left/Right), Backspace and Delete, history (Up/Down), a
+// length limit and password masking. Text is edited as runes, so keymaps
+// with non-ASCII letters work; raw codes of 0x80 and above are assembled
+// into UTF-8 sequences.
+//
+//	ed := tdeck.NewLineEditor(32)
+//	for {
+//		kb.Poll()
+//		for ev, ok := kb.NextEvent(); ok; ev, ok = kb.NextEvent() {
+//			if ed.HandleKey(ev) {
+//				println("entered:", ed.Text())
+//				ed.Reset()
+//			}
+//		}
+//		ed.Render(&display, 10, 10, 30, 2, fg, bg)
+//	}
+type LineEditor struct {
+	// MaxLen is the maximum number of runes, 0 for no limit.
+	MaxLen int
+
+	// Password hides the text behind Mask when rendering.
+	Password bool
+	Mask     rune
+
+	// HistorySize is the number of submitted lines kept for Up/Down.
+	HistorySize int
+
+	buf     []rune
+	cursor  int
+	scroll  int
+	history []string
+	histPos int    // index into history while browsing, len(history) otherwise
+	draft   []rune // line being edited before browsing history
+	pending [utf8.UTFMax]byte
+	npend   int
+	dirty   bool
+}
+
+// NewLineEditor creates an editor limited to maxLen runes (0 for no limit).
+func NewLineEditor(maxLen int) *LineEditor {
+	return &LineEditor{
+		MaxLen:      maxLen,
+		Mask:        '*',
+		HistorySize: defaultHistory,
+		dirty:       true,
+	}
+}
+
+// HandleKey applies a key event to the line. It returns true when Enter was
+// pressed; the line is then added to the history and can be read with Text.
+func (e *LineEditor) HandleKey(ev KeyEvent) bool {
+	if !ev.Pressed {
+		return false
+	}
+	switch ev.Code {
+	case KeyEnter:
+		e.addHistory()
+		e.dirty = true
+		return true
+	case KeyLeft:
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case KeyRight:
+		if e.cursor < len(e.buf) {
+			e.cursor++
+		}
+	case KeyUp:
+		e.browse(-1)
+	case KeyDown:
+		e.browse(1)
+	case KeyBackspace:
+		if e.cursor > 0 {
+			e.buf = append(e.buf[:e.cursor-1], e.buf[e.cursor:]...)
+			e.cursor--
+		}
+	case KeyDelete:
+		if e.cursor < len(e.buf) {
+			e.buf = append(e.buf[:e.cursor], e.buf[e.cursor+1:]...)
+		}
+	case KeyEscape:
+		e.buf = e.buf[:0]
+		e.cursor = 0
+	default:
+		switch {
+		case ev.Rune != 0:
+			e.insert(ev.Rune)
+		case ev.Code >= 0x80:
+			e.compose(ev.Code)
+		default:
+			return false
+		}
+	}
+	e.dirty = true
+	return false
+}
+
+// compose collects the bytes of a UTF-8 sequence and inserts the rune once
+// it is complete.
+func (e *LineEditor) compose(b byte) {
+	if e.npend == len(e.pending) || (e.npend > 0 && b&0xC0 != 0x80) {
+		e.npend = 0
+	}
+	e.pending[e.npend] = b
+	e.npend++
+	if utf8.FullRune(e.pending[:e.npend]) {
+		r, _ := utf8.DecodeRune(e.pending[:e.npend])
+		e.npend = 0
+		if r != utf8.RuneError {
+			e.insert(r)
+		}
+	}
+}
+
+func (e *LineEditor) insert(r rune) {
+	if e.MaxLen > 0 && len(e.buf) >= e.MaxLen {
+		return
+	}
+	e.buf = append(e.buf, 0)
+	copy(e.buf[e.cursor+1:], e.buf[e.cursor:])
+	e.buf[e.cursor] = r
+	e.cursor++
+}
+
+func (e *LineEditor) addHistory() {
+	e.histPos = len(e.history)
+	if len(e.buf) == 0 || e.HistorySize <= 0 {
+		return
+	}
+	line := string(e.buf)
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	if len(e.history) >= e.HistorySize {
+		e.history = append(e.history[:0], e.history[1:]...)
+	}
+	e.history = append(e.history, line)
+	e.histPos = len(e.history)
+}
+
+// browse moves through the history; moving past the newest entry restores
+// the line that was being edited.
+func (e *LineEditor) browse(dir int) {
+	pos := e.histPos + dir
+	if pos < 0 || pos > len(e.history) {
+		return
+	}
+	if e.histPos == len(e.history) {
+		e.draft = append(e.draft[:0], e.buf...)
+	}
+	e.histPos = pos
+	if pos == len(e.history) {
+		e.buf = append(e.buf[:0], e.draft...)
+	} else {
+		e.buf = append(e.buf[:0], []rune(e.history[pos])...)
+	}
+	e.cursor = len(e.buf)
+}
+
+// Text returns the current line.
+func (e *LineEditor) Text() string {
+	return string(e.buf)
+}
+
+// SetText replaces the line and moves the cursor to its end.
+func (e *LineEditor) SetText(s string) {
+	e.buf = append(e.buf[:0], []rune(s)...)
+	if e.MaxLen > 0 && len(e.buf) > e.MaxLen {
+		e.buf = e.buf[:e.MaxLen]
+	}
+	e.cursor = len(e.buf)
+	e.dirty = true
+}
+
+// Reset clears the line. The history is kept.
+func (e *LineEditor) Reset() {
+	e.buf = e.buf[:0]
+	e.cursor = 0
+	e.scroll = 0
+	e.histPos = len(e.history)
+	e.npend = 0
+	e.dirty = true
+}
+
+// Cursor returns the cursor position in runes.
+func (e *LineEditor) Cursor() int {
+	return e.cursor
+}
+
+// Display returns the line as it is shown, masked in password mode.
+func (e *LineEditor) Display() string {
+	if !e.Password {
+		return string(e.buf)
+	}
+	m := make([]rune, len(e.buf))
+	for i := range m {
+		m[i] = e.Mask
+	}
+	return string(m)
+}
+
+// Render draws the line in a field cols characters wide at (x, y), scrolling
+// so that the cursor stays visible, and draws the cursor as an underline. It
+// only redraws when the line changed since the last call.
+func (e *LineEditor) Render(r TextRenderer, x, y int16, cols, scale int, fg, bg color.RGBA) {
+	if !e.dirty || cols <= 0 {
+		return
+	}
+	e.dirty = false
+	if e.cursor < e.scroll {
+		e.scroll = e.cursor
+	}
+	if e.cursor >= e.scroll+cols {
+		e.scroll = e.cursor - cols + 1
+	}
+	text := []rune(e.Display())
+	end := e.scroll + cols
+	if end > len(text) {
+		end = len(text)
+	}
+	cw, ch := int16(glyphW*scale), int16(glyphH*scale)
+	r.FillRectangle(x, y, cw*int16(cols), ch+int16(scale), bg)
+	r.DrawString(x, y, string(text[e.scroll:end]), fg, scale)
+	cx := x + cw*int16(e.cursor-e.scroll)
+	r.FillRectangle(cx, y+ch, cw-int16(scale), int16(scale), fg)
+}
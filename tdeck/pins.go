@@ -21,6 +21,9 @@ const (
 
 	BatteryADCPin = 4 // ADC для напряжения батареи (через делитель)
 
+	KeyboardINT = 46 // BOARD_KEYBOARD_INT
+	TouchINT    = 16 // BOARD_TOUCH_INT, тачскрин GT911 (пакет gt911)
+
 	// SD card (SPI): BOARD_SDCARD_*, BOARD_SPI_* в utilities.h
 	SDCardCS   = 39
 	SDCardSCK  = 40
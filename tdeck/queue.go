@@ -0,0 +1,37 @@
+package tdeck
+
+// queue is a fixed-size FIFO of events. When it is full the oldest event is
+// dropped, so a slow reader loses history rather than new input.
+type queue[T any] struct {
+	buf  []T
+	head int
+	n    int
+}
+
+func newQueue[T any](size int) queue[T] {
+	return queue[T]{buf: make([]T, size)}
+}
+
+func (q *queue[T]) push(v T) {
+	if q.n == len(q.buf) {
+		q.head = (q.head + 1) % len(q.buf)
+		q.n--
+	}
+	q.buf[(q.head+q.n)%len(q.buf)] = v
+	q.n++
+}
+
+func (q *queue[T]) pop() (T, bool) {
+	var v T
+	if q.n == 0 {
+		return v, false
+	}
+	v = q.buf[q.head]
+	q.head = (q.head + 1) % len(q.buf)
+	q.n--
+	return v, true
+}
+
+func (q *queue[T]) len() int {
+	return q.n
+}
@@ -14,9 +14,15 @@
 //	    }
 //	    time.Sleep(10 * time.Millisecond)
 //	}
+//
+// For debounced clicks, double clicks, long presses and drags use Poll and
+// NextButtonEvent instead of Read().OK.
 package tdeck
 
-import "machine"
+import (
+	"machine"
+	"sync/atomic"
+)
 
 type TrackballState struct {
 	Left  bool
@@ -33,10 +39,27 @@ type Trackball struct {
 	down  machine.Pin
 	ok    machine.Pin
 	last  [4]bool
+	irq   bool
+	edges [4]int32 // edges counted by interrupts, same order as last
+
+	button  buttonState
+	timing  ButtonTiming
+	events  queue[ButtonEvent]
+	handler func(ButtonEvent)
+	nav     *Navigator
 }
 
 func NewTrackball(left, up, right, down, ok machine.Pin) *Trackball {
-	t := &Trackball{left: left, up: up, right: right, down: down, ok: ok}
+	t := &Trackball{
+		left:   left,
+		up:     up,
+		right:  right,
+		down:   down,
+		ok:     ok,
+		timing: DefaultButtonTiming(),
+		events: newQueue[ButtonEvent](buttonQueueSize),
+		nav:    NewNavigator(),
+	}
 	for _, p := range []machine.Pin{left, up, right, down, ok} {
 		if p != machine.NoPin {
 			p.Configure(machine.PinConfig{Mode: machine.PinInputPullup})
@@ -72,6 +95,9 @@ func (t *Trackball) Read() TrackballState {
 	}
 }
 
+// ReadKey returns a letter for the pin that is low right now: 'O' for the
+// button, 'L', 'U', 'R' or 'D' for a direction, 0 for none. Direction pins
+// only stay low for a moment while rolling; use ReadDirection for menus.
 func (t *Trackball) ReadKey() (byte, error) {
 	s := t.Read()
 	if s.OK {
@@ -92,10 +118,62 @@ func (t *Trackball) ReadKey() (byte, error) {
 	return 0, nil
 }
 
+// ReadDirection reads the motion since the last call and returns the
+// navigation step it completes, see Navigator. Don't mix it with ReadMotion
+// or Poll, they consume the same motion.
+func (t *Trackball) ReadDirection() Direction {
+	return t.nav.Update(t.ReadMotion())
+}
+
+// Navigator returns the navigator used by ReadDirection, to tune it.
+func (t *Trackball) Navigator() *Navigator {
+	return t.nav
+}
+
+// EnableInterrupts counts edges on the four direction pins from pin change
+// interrupts. ReadMotion then drains the counters instead of sampling pin
+// levels, so no steps are lost however rarely it is called.
+func (t *Trackball) EnableInterrupts() error {
+	for i, p := range t.motionPins() {
+		if p == machine.NoPin {
+			continue
+		}
+		err := p.SetInterrupt(machine.PinToggle, func(machine.Pin) {
+			atomic.AddInt32(&t.edges[i], 1)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	t.irq = true
+	return nil
+}
+
+// DisableInterrupts goes back to sampling pin levels in ReadMotion.
+func (t *Trackball) DisableInterrupts() {
+	for _, p := range t.motionPins() {
+		if p != machine.NoPin {
+			p.SetInterrupt(0, nil)
+		}
+	}
+	t.irq = false
+}
+
+func (t *Trackball) motionPins() [4]machine.Pin {
+	return [4]machine.Pin{t.right, t.up, t.left, t.down}
+}
+
 // ReadMotion returns delta since last call (official firmware style: each pin
 // state change = one step). Roll the trackball to get dx, dy.
 func (t *Trackball) ReadMotion() (dx, dy int) {
-	pins := []machine.Pin{t.right, t.up, t.left, t.down}
+	if t.irq {
+		right := int(atomic.SwapInt32(&t.edges[0], 0))
+		up := int(atomic.SwapInt32(&t.edges[1], 0))
+		left := int(atomic.SwapInt32(&t.edges[2], 0))
+		down := int(atomic.SwapInt32(&t.edges[3], 0))
+		return right - left, down - up
+	}
+	pins := t.motionPins()
 	for i := 0; i < 4; i++ {
 		if pins[i] == machine.NoPin {
 			continue
@@ -0,0 +1,191 @@
+package tdeck
+
+import "time"
+
+// ButtonAction is the kind of a trackball button event.
+type ButtonAction uint8
+
+const (
+	ButtonDown        ButtonAction = iota + 1 // button pressed, after debouncing
+	ButtonUp                                  // button released
+	ButtonClick                               // short press, not followed by a second one
+	ButtonDoubleClick                         // two short presses within DoubleClick
+	ButtonLongPress                           // button held for LongPress without rolling
+	ButtonDrag                                // ball rolled while the button is held, DX/DY set
+	ButtonDragEnd                             // button released after dragging
+)
+
+// ButtonEvent is a gesture of the trackball OK button.
+type ButtonEvent struct {
+	Action ButtonAction
+	DX, DY int       // motion for ButtonDrag
+	Time   time.Time // when the event was detected
+}
+
+// ButtonTiming configures how the OK button is debounced and how gestures
+// are told apart.
+type ButtonTiming struct {
+	// Debounce is how long the pin must keep a level before it is accepted.
+	Debounce time.Duration
+
+	// DoubleClick is the longest pause between two clicks of a double click.
+	// A click is reported only after this time, 0 reports it on release.
+	DoubleClick time.Duration
+
+	// LongPress is how long the button must be held for ButtonLongPress.
+	LongPress time.Duration
+
+	// DragThreshold is the number of steps rolled while the button is held
+	// after which the press becomes a drag.
+	DragThreshold int
+}
+
+// DefaultButtonTiming returns the button timing used by NewTrackball.
+func DefaultButtonTiming() ButtonTiming {
+	return ButtonTiming{
+		Debounce:      20 * time.Millisecond,
+		DoubleClick:   300 * time.Millisecond,
+		LongPress:     DefaultLongPress,
+		DragThreshold: 2,
+	}
+}
+
+const buttonQueueSize = 16
+
+// buttonState tracks the debounced OK button and the gesture in progress.
+type buttonState struct {
+	raw      bool      // last sampled level
+	rawAt    time.Time // when raw last changed
+	down     bool      // debounced level
+	downAt   time.Time
+	moved    int // steps rolled during this press
+	dragging bool
+	long     bool // long press already reported
+	second   bool // this press follows a pending click
+	click    bool // click waiting for a possible second one
+	clickAt  time.Time
+}
+
+// SetButtonTiming changes how the OK button is debounced and interpreted.
+func (t *Trackball) SetButtonTiming(bt ButtonTiming) {
+	t.timing = bt
+}
+
+// SetButtonHandler sets a function that Poll calls for every button event.
+// Pass nil to queue events for NextButtonEvent again.
+func (t *Trackball) SetButtonHandler(h func(ButtonEvent)) {
+	t.handler = h
+}
+
+// NextButtonEvent returns the oldest queued button event. The queue holds
+// the last 16 events.
+func (t *Trackball) NextButtonEvent() (ButtonEvent, bool) {
+	return t.events.pop()
+}
+
+// Poll reads the motion since the last call, updates the OK button gesture
+// and queues the resulting button events. The motion is returned so that it
+// can still drive a cursor. Call it regularly from the main loop instead of
+// ReadMotion and Read.
+func (t *Trackball) Poll() (dx, dy int) {
+	dx, dy = t.ReadMotion()
+	now := time.Now()
+	t.updateButton(t.pressed(t.ok), dx, dy, now)
+	if t.handler != nil {
+		for {
+			ev, ok := t.events.pop()
+			if !ok {
+				break
+			}
+			t.handler(ev)
+		}
+	}
+	return dx, dy
+}
+
+func (t *Trackball) updateButton(level bool, dx, dy int, now time.Time) {
+	b := &t.button
+	if level != b.raw {
+		b.raw = level
+		b.rawAt = now
+	}
+	if b.raw != b.down && now.Sub(b.rawAt) >= t.timing.Debounce {
+		if b.raw {
+			t.press(now)
+		} else {
+			t.release(now)
+		}
+	}
+	if !b.down {
+		if b.click && now.Sub(b.clickAt) >= t.timing.DoubleClick {
+			b.click = false
+			t.emitButton(ButtonClick, 0, 0, now)
+		}
+		return
+	}
+	if b.dragging {
+		if dx != 0 || dy != 0 {
+			t.emitButton(ButtonDrag, dx, dy, now)
+		}
+		return
+	}
+	b.moved += abs(dx) + abs(dy)
+	if b.moved >= t.timing.DragThreshold && t.timing.DragThreshold > 0 {
+		t.flushClick(now)
+		b.dragging = true
+		t.emitButton(ButtonDrag, dx, dy, now)
+		return
+	}
+	if !b.long && now.Sub(b.downAt) >= t.timing.LongPress {
+		t.flushClick(now)
+		b.long = true
+		t.emitButton(ButtonLongPress, 0, 0, now)
+	}
+}
+
+func (t *Trackball) press(now time.Time) {
+	b := &t.button
+	if b.click && now.Sub(b.clickAt) >= t.timing.DoubleClick {
+		t.flushClick(now)
+	}
+	b.down = true
+	b.downAt = now
+	b.moved = 0
+	b.dragging = false
+	b.long = false
+	b.second = b.click
+	t.emitButton(ButtonDown, 0, 0, now)
+}
+
+func (t *Trackball) release(now time.Time) {
+	b := &t.button
+	b.down = false
+	t.emitButton(ButtonUp, 0, 0, now)
+	switch {
+	case b.dragging:
+		t.emitButton(ButtonDragEnd, 0, 0, now)
+	case b.long:
+	case b.second && b.click:
+		b.click = false
+		t.emitButton(ButtonDoubleClick, 0, 0, now)
+	case t.timing.DoubleClick <= 0:
+		t.emitButton(ButtonClick, 0, 0, now)
+	default:
+		b.click = true
+		b.clickAt = now
+	}
+	b.second = false
+}
+
+// flushClick reports a pending click once the following press turned out to
+// be a drag or long press rather than the second half of a double click.
+func (t *Trackball) flushClick(now time.Time) {
+	if t.button.click {
+		t.button.click = false
+		t.emitButton(ButtonClick, 0, 0, now)
+	}
+}
+
+func (t *Trackball) emitButton(a ButtonAction, dx, dy int, now time.Time) {
+	t.events.push(ButtonEvent{Action: a, DX: dx, DY: dy, Time: now})
+}
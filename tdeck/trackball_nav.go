@@ -0,0 +1,152 @@
+package tdeck
+
+import "time"
+
+// Direction is a discrete navigation step.
+type Direction uint8
+
+const (
+	DirNone Direction = iota
+	DirUp
+	DirDown
+	DirLeft
+	DirRight
+)
+
+// Key returns the arrow key code for d, so trackball steps can drive the
+// same code as the keyboard arrows. It returns KeyNone for DirNone.
+func (d Direction) Key() byte {
+	switch d {
+	case DirUp:
+		return KeyUp
+	case DirDown:
+		return KeyDown
+	case DirLeft:
+		return KeyLeft
+	case DirRight:
+		return KeyRight
+	}
+	return KeyNone
+}
+
+const (
+	DefaultNavThreshold  = 3
+	DefaultNavHysteresis = 2
+	DefaultNavIdle       = 300 * time.Millisecond
+)
+
+// Navigator turns trackball motion into discrete steps for menus and
+// grids. Motion is accumulated per axis; a step is made along the dominant
+// axis once it has moved Threshold steps. Changing direction takes
+// Hysteresis more, so a slight wobble back doesn't undo the last step.
+// Motion is forgotten after Idle without rolling.
+//
+//	nav := tdeck.NewNavigator()
+//	for {
+//		switch nav.Update(tb.ReadMotion()) {
+//		case tdeck.DirUp:
+//			menu.Prev()
+//		case tdeck.DirDown:
+//			menu.Next()
+//		}
+//	}
+type Navigator struct {
+	Threshold  int
+	Hysteresis int
+	Idle       time.Duration
+
+	ax, ay int
+	last   Direction
+	moved  time.Time
+}
+
+// NewNavigator creates a navigator with the default threshold, hysteresis
+// and idle time.
+func NewNavigator() *Navigator {
+	return &Navigator{
+		Threshold:  DefaultNavThreshold,
+		Hysteresis: DefaultNavHysteresis,
+		Idle:       DefaultNavIdle,
+	}
+}
+
+// Update adds a motion delta and returns the step it completes, if any. At
+// most one step is returned per call; motion beyond what a change of
+// direction needs is dropped, so a fast roll does not queue up steps.
+func (n *Navigator) Update(dx, dy int) Direction {
+	return n.UpdateAt(dx, dy, time.Now())
+}
+
+// UpdateAt is Update with an explicit timestamp.
+func (n *Navigator) UpdateAt(dx, dy int, now time.Time) Direction {
+	if dx != 0 || dy != 0 {
+		if now.Sub(n.moved) > n.Idle {
+			n.Reset()
+		}
+		n.moved = now
+		n.ax += dx
+		n.ay += dy
+	}
+	// A change of direction needs Threshold+Hysteresis: the limit must not
+	// be lower, or reversing would be impossible until Idle.
+	limit := n.Threshold + max(n.Threshold, n.Hysteresis)
+	n.ax = clampInt(n.ax, -limit, limit)
+	n.ay = clampInt(n.ay, -limit, limit)
+
+	var d Direction
+	var travel int
+	switch {
+	case abs(n.ax) > abs(n.ay):
+		d, travel = DirRight, n.ax
+		if n.ax < 0 {
+			d, travel = DirLeft, -n.ax
+		}
+	case abs(n.ay) > abs(n.ax):
+		d, travel = DirDown, n.ay
+		if n.ay < 0 {
+			d, travel = DirUp, -n.ay
+		}
+	default:
+		return DirNone
+	}
+	need := n.Threshold
+	if n.last != DirNone && d != n.last {
+		need += n.Hysteresis
+	}
+	if travel < need {
+		return DirNone
+	}
+	switch d {
+	case DirRight:
+		n.ax -= n.Threshold
+	case DirLeft:
+		n.ax += n.Threshold
+	case DirDown:
+		n.ay -= n.Threshold
+	case DirUp:
+		n.ay += n.Threshold
+	}
+	if d == DirLeft || d == DirRight {
+		n.ay = 0
+	} else {
+		n.ax = 0
+	}
+	n.last = d
+	return d
+}
+
+// Reset forgets accumulated motion and the last direction.
+func (n *Navigator) Reset() {
+	n.ax, n.ay = 0, 0
+	n.last = DirNone
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
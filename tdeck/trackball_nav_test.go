@@ -0,0 +1,64 @@
+package tdeck
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNavigator(t *testing.T) {
+	tests := []struct {
+		name       string
+		threshold  int
+		hysteresis int
+		moves      []int // vertical deltas, one Update each
+		want       []Direction
+	}{
+		{
+			name: "step", threshold: 3, hysteresis: 2,
+			moves: []int{1, 1, 1},
+			want:  []Direction{DirNone, DirNone, DirDown},
+		},
+		{
+			name: "wobble back is not a step", threshold: 3, hysteresis: 2,
+			moves: []int{3, -1, -1, -1, -1},
+			want:  []Direction{DirDown, DirNone, DirNone, DirNone, DirNone},
+		},
+		{
+			name: "reversal needs threshold plus hysteresis", threshold: 3, hysteresis: 2,
+			moves: []int{3, -1, -1, -1, -1, -1},
+			want:  []Direction{DirDown, DirNone, DirNone, DirNone, DirNone, DirUp},
+		},
+		{
+			name: "fast roll is clamped", threshold: 3, hysteresis: 2,
+			moves: []int{20, 0, 0},
+			want:  []Direction{DirDown, DirDown, DirNone},
+		},
+		{
+			name: "reversal with hysteresis above threshold", threshold: 2, hysteresis: 5,
+			moves: []int{2, -1, -1, -1, -1, -1, -1, -1},
+			want:  []Direction{DirDown, DirNone, DirNone, DirNone, DirNone, DirNone, DirNone, DirUp},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNavigator()
+			n.Threshold, n.Hysteresis = tt.threshold, tt.hysteresis
+			now := time.Unix(0, 0)
+			for i, dy := range tt.moves {
+				now = now.Add(10 * time.Millisecond)
+				if got := n.UpdateAt(0, dy, now); got != tt.want[i] {
+					t.Fatalf("move %d (%+d): got %v, want %v", i, dy, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNavigatorIdleReset(t *testing.T) {
+	n := NewNavigator()
+	now := time.Unix(0, 0)
+	n.UpdateAt(0, 2, now)
+	if d := n.UpdateAt(0, 1, now.Add(2*DefaultNavIdle)); d != DirNone {
+		t.Fatalf("motion before Idle counted: %v", d)
+	}
+}